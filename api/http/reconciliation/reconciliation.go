@@ -1,12 +1,21 @@
 package reconciliation
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/elkoshar/reconciliation-app/api"
 	"github.com/elkoshar/reconciliation-app/pkg/response"
+	"github.com/elkoshar/reconciliation-app/pkg/validator"
 	"github.com/elkoshar/reconciliation-app/service/reconciliation"
 )
 
@@ -33,10 +42,33 @@ func Init(service api.ReconciliationService) {
 // @Param Accept-Language header string true "accept language" default(id)
 // @Param start_date formData string true "start date format YYYY-MM-DD" example(2023-01-01)
 // @Param end_date formData string true "end date format YYYY-MM-DD" example(2023-01-31)
-// @Param system_data formData file true "system data file upload"
-// @Param bank_csv formData file false "bank CSV file upload"
+// @Param system_data formData file true "system data file upload, CSV or XLSX"
+// @Param bank_csv formData file false "bank statement file upload, CSV, XLSX, MT940, camt.053/camt.054 XML, OFX/QFX or BAI2"
+// @Param tolerance_amount formData number false "absolute amount tolerance for near-matches" example(500)
+// @Param tolerance_percent formData number false "percentage amount tolerance for near-matches" example(0.5)
+// @Param bank_tolerances formData string false "per bank tolerance as JSON object keyed by bank name" example({"Stmt-BCA.csv":{"amount":500,"percent":0}})
+// @Param settlement_days formData integer false "number of settlement days a bank line may be posted after the system transaction" minimum(0) maximum(31) example(2)
+// @Param skip_weekends formData boolean false "do not count weekends as settlement days"
+// @Param holidays formData string false "comma separated holiday dates format YYYY-MM-DD" example(2025-12-25,2025-12-26)
+// @Param min_confidence formData number false "matches scoring below this confidence (0 to 1) are reported as unmatched" example(0.8)
+// @Param max_group_size formData integer false "maximum number of transactions grouped in a split match, 0 disables split matching" minimum(0) maximum(5) example(3)
+// @Param match_mode formData string false "discrepancy matching mode" Enums(greedy, optimal) default(greedy)
+// @Param reference_column formData string false "bank statement column holding our transaction ID" example(description)
+// @Param reference_pattern formData string false "regular expression extracting the transaction ID from the reference column" example(trx-[a-z]+-\d+)
+// @Param bank_references formData string false "per bank reference rule as JSON object keyed by bank name" example({"Stmt-BCA.csv":{"column":"remark","pattern":"TRX\d+"}})
+// @Param system_profile formData string false "name of the system export profile" default(default)
+// @Param bank_profile formData []string false "name of the statement profile of each bank_csv file in upload order, a single value applies to every file, auto detects the profile, as does empty when named profiles are configured" collectionFormat(multi)
+// @Param bank_format formData []string false "file format of each bank_csv file in upload order, a single value applies to every file, auto or empty detects the format" collectionFormat(multi) Enums(csv, xlsx, mt940, camt, ofx, bai2, auto)
+// @Param sheet_name formData string false "worksheet of XLSX uploads, the first one when empty" example(Sheet1)
+// @Param header_row formData integer false "row of XLSX uploads holding the header, counted from one" example(1)
+// @Param currency formData string false "ISO 4217 currency of the transactions whose file does not tell" example(IDR)
+// @Param strict formData boolean false "fail the request when any row or file cannot be parsed"
+// @Param fx_rates formData file false "exchange rates as CSV with date,from,to,rate header or JSON array, overriding the configured rates"
+// @Param fx_tolerance_amount formData number false "absolute tolerance between the converted system amount and the bank amount" example(1)
+// @Param fx_tolerance_percent formData number false "percentage tolerance between the converted system amount and the bank amount" example(0.5)
 // @Success 200 {object} response.Response{data=reconciliation.ReconciliationResult} "Success Response"
 // @Failure 400 "Bad Request"
+// @Failure 422 {object} response.Response{data=[]reconciliation.ParseError} "Rejected rows in strict mode"
 // @Failure 500 "InternalServerError"
 // @Router /reconciliation [post]
 func Reconciliation(w http.ResponseWriter, r *http.Request) {
@@ -66,7 +98,212 @@ func Reconciliation(w http.ResponseWriter, r *http.Request) {
 	}
 	defer sysFile.Close()
 
-	result, err = reconService.Reconcile(startDate, endDate, sysFile, r.MultipartForm, nil, nil)
+	opts, err := parseReconcileOptions(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	result, err = reconService.Reconcile(startDate, endDate, sysFile, r.MultipartForm, nil, nil, opts)
+
+	var strictErr *reconciliation.StrictModeError
+	if errors.As(err, &strictErr) {
+		slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Input Rejected. err=%v", err))
+		resp.SetError(err, http.StatusUnprocessableEntity)
+		resp.Data = strictErr.Errors
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Process Failed. err=%v", err))
+		resp.SetError(err, http.StatusInternalServerError)
+		return
+	}
+
+	resp.Data = result
+}
+
+// ReconciliationStream : HTTP Handler for streaming reconciliation of large uploads
+// @Summary Streaming Reconciliation Process
+// @Description ReconciliationStream reads the upload part by part and spools it to disk by day, so files larger than memory can be reconciled. It takes the same form fields as the reconciliation process, which must all precede the uploaded files. Unlike the reconciliation process, a reference match is only found within the settlement window, and a split match never groups system transactions of different days.
+// @Tags Reconciliation
+// @Accept multipart/form-data
+// @Produce json
+// @Param Accept-Language header string true "accept language" default(id)
+// @Param start_date formData string true "start date format YYYY-MM-DD" example(2023-01-01)
+// @Param end_date formData string true "end date format YYYY-MM-DD" example(2023-01-31)
+// @Param system_data formData file true "system data file upload, CSV or XLSX"
+// @Param bank_csv formData file false "bank statement file upload, CSV, XLSX, MT940, camt.053/camt.054 XML, OFX/QFX or BAI2"
+// @Param fx_rates formData file false "exchange rates as CSV with date,from,to,rate header or JSON array, overriding the configured rates"
+// @Success 200 {object} response.Response{data=reconciliation.ReconciliationResult} "Success Response"
+// @Failure 400 "Bad Request"
+// @Failure 422 {object} response.Response{data=[]reconciliation.ParseError} "Rejected rows in strict mode"
+// @Failure 500 "InternalServerError"
+// @Router /reconciliation/stream [post]
+func ReconciliationStream(w http.ResponseWriter, r *http.Request) {
+	resp := response.Response{}
+	defer resp.Render(w, r)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("Read Multipart Form Failed. err=%v", err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	var (
+		stream    *reconciliation.Stream
+		hasSystem bool
+	)
+	defer func() {
+		if stream != nil {
+			stream.Close()
+		}
+	}()
+
+	r.Form = url.Values{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("Read Multipart Form Failed. err=%v", err))
+			resp.SetError(err, http.StatusBadRequest)
+			return
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			if stream != nil {
+				err = fmt.Errorf("form field %q must precede the uploaded files", name)
+				resp.SetError(err, http.StatusBadRequest)
+				return
+			}
+			value, err := io.ReadAll(part)
+			if err != nil {
+				resp.SetError(err, http.StatusBadRequest)
+				return
+			}
+			r.Form.Add(name, string(value))
+			continue
+		}
+
+		if stream == nil {
+			opts, err := parseReconcileOptions(r)
+			if err != nil {
+				slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+				resp.SetError(err, http.StatusBadRequest)
+				return
+			}
+			stream, err = reconService.NewStream(r.Form.Get("start_date"), r.Form.Get("end_date"), opts)
+			if err != nil {
+				slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Process Failed. err=%v", err))
+				resp.SetError(err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		switch name {
+		case "system_data":
+			hasSystem = true
+			err = stream.AddSystemData(part)
+		case "bank_csv":
+			err = stream.AddBankFile(part.FileName(), part)
+		case "fx_rates":
+			if err = stream.AddFXRates(part); err != nil {
+				resp.SetError(err, http.StatusBadRequest)
+				return
+			}
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Process Failed. err=%v", err))
+			resp.SetError(err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !hasSystem {
+		err = errors.New("http: no such file")
+		slog.WarnContext(r.Context(), fmt.Sprintf("Get System Data File Failed. err=%v", err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := stream.Finish()
+
+	var strictErr *reconciliation.StrictModeError
+	if errors.As(err, &strictErr) {
+		slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Input Rejected. err=%v", err))
+		resp.SetError(err, http.StatusUnprocessableEntity)
+		resp.Data = strictErr.Errors
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Process Failed. err=%v", err))
+		resp.SetError(err, http.StatusInternalServerError)
+		return
+	}
+
+	resp.Data = result
+}
+
+// ReconciliationJSON : HTTP Handler for reconciling transactions given as JSON
+// @Summary JSON Reconciliation Process
+// @Description ReconciliationJSON reconciles system and bank transactions sent in a JSON body, for services that do not produce files. The optional matching parameters of the reconciliation process are taken from the query string.
+// @Tags Reconciliation
+// @Accept json
+// @Produce json
+// @Param Accept-Language header string true "accept language" default(id)
+// @Param request body ReconcileRequest true "period and transactions to reconcile"
+// @Param currency query string false "ISO 4217 currency of the transactions without one" example(IDR)
+// @Param tolerance_amount query number false "absolute amount tolerance for near-matches" example(500)
+// @Param settlement_days query integer false "number of settlement days a bank line may be posted after the system transaction" minimum(0) maximum(31) example(2)
+// @Param match_mode query string false "discrepancy matching mode" Enums(greedy, optimal) default(greedy)
+// @Success 200 {object} response.Response{data=reconciliation.ReconciliationResult} "Success Response"
+// @Failure 400 "Bad Request"
+// @Failure 415 "Unsupported Media Type"
+// @Failure 500 "InternalServerError"
+// @Router /reconciliation/json [post]
+func ReconciliationJSON(w http.ResponseWriter, r *http.Request) {
+	resp := response.Response{}
+	defer resp.Render(w, r)
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		err := fmt.Errorf("unsupported content type %q (expected application/json)", r.Header.Get("Content-Type"))
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(err, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body ReconcileRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 32<<20)).Decode(&body); err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(fmt.Errorf("invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	body.normalize()
+	if _, err := validator.ValidateStruct(body); err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseReconcileOptions(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	systemTransactions, bankTransactions, err := body.transactions(opts.Currency)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf(ErrParseValidateMsg, err))
+		resp.SetError(err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := reconService.Reconcile(body.StartDate, body.EndDate, nil, nil, systemTransactions, bankTransactions, opts)
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("Reconciliation Process Failed. err=%v", err))
 		resp.SetError(err, http.StatusInternalServerError)
@@ -75,3 +312,278 @@ func Reconciliation(w http.ResponseWriter, r *http.Request) {
 
 	resp.Data = result
 }
+
+// Rules : HTTP Handler for listing the active matching rules
+// @Summary Matching Rules
+// @Description Rules returns the active declarative matching rules
+// @Tags Reconciliation
+// @Produce json
+// @Param Accept-Language header string true "accept language" default(id)
+// @Success 200 {object} response.Response{data=[]reconciliation.Rule} "Success Response"
+// @Router /reconciliation/rules [get]
+func Rules(w http.ResponseWriter, r *http.Request) {
+	resp := response.Response{}
+	defer resp.Render(w, r)
+
+	rules := reconService.Rules()
+	if rules == nil {
+		rules = []reconciliation.Rule{}
+	}
+
+	resp.Data = rules
+}
+
+type toleranceParam struct {
+	Amount  float64 `json:"amount"`
+	Percent float64 `json:"percent"`
+}
+
+func (p toleranceParam) toTolerance(field string) (reconciliation.Tolerance, error) {
+	if p.Amount < 0 || p.Percent < 0 {
+		return reconciliation.Tolerance{}, fmt.Errorf("invalid %s (must not be negative)", field)
+	}
+	return reconciliation.Tolerance{
+		Amount:  reconciliation.ToMoney(p.Amount),
+		Percent: p.Percent,
+	}, nil
+}
+
+type referenceParam struct {
+	Column  string `json:"column"`
+	Pattern string `json:"pattern"`
+}
+
+func (p referenceParam) toReferenceRule(field string) (reconciliation.ReferenceRule, error) {
+	rule := reconciliation.ReferenceRule{Column: p.Column, Pattern: p.Pattern}
+	if err := rule.Validate(); err != nil {
+		return rule, fmt.Errorf("invalid %s (%v)", field, err)
+	}
+	return rule, nil
+}
+
+// ReconcileRequest is the body of the JSON reconciliation endpoint.
+type ReconcileRequest struct {
+	StartDate          string                     `json:"start_date" validate:"required,datetime=2006-01-02" example:"2025-01-01"`
+	EndDate            string                     `json:"end_date" validate:"required,datetime=2006-01-02" example:"2025-01-31"`
+	SystemTransactions []SystemTransactionRequest `json:"system_transactions" validate:"dive"`
+	BankTransactions   []BankTransactionRequest   `json:"bank_transactions" validate:"dive"`
+}
+
+// SystemTransactionRequest is a system transaction of a ReconcileRequest.
+// Amount is a decimal number or string in the major unit of the currency.
+type SystemTransactionRequest struct {
+	TransactionID   string      `json:"trx_id" validate:"required" example:"TRX001"`
+	Amount          json.Number `json:"amount" validate:"required,numeric" swaggertype:"string" example:"100.50"`
+	Currency        string      `json:"currency" validate:"omitempty,iso4217" example:"IDR"`
+	Type            string      `json:"type" validate:"required,oneof=DEBIT CREDIT" example:"CREDIT"`
+	TransactionTime time.Time   `json:"transaction_time" validate:"required" example:"2025-01-15T10:30:00+07:00"`
+}
+
+// BankTransactionRequest is a bank statement line of a ReconcileRequest.
+// Amount is signed, negative for debits.
+type BankTransactionRequest struct {
+	BankName    string      `json:"bank_name" validate:"required" example:"BCA"`
+	UniqueID    string      `json:"unique_id" validate:"required" example:"BK0001"`
+	Amount      json.Number `json:"amount" validate:"required,numeric" swaggertype:"string" example:"-250.00"`
+	Currency    string      `json:"currency" validate:"omitempty,iso4217" example:"IDR"`
+	Date        string      `json:"date" validate:"required,datetime=2006-01-02" example:"2025-01-15"`
+	Description string      `json:"description"`
+	Reference   string      `json:"reference" example:"TRX001"`
+}
+
+// normalize upper-cases the currency codes, which are validated as ISO 4217
+// codes but accepted in any case.
+func (req *ReconcileRequest) normalize() {
+	for i := range req.SystemTransactions {
+		req.SystemTransactions[i].Currency = strings.ToUpper(strings.TrimSpace(req.SystemTransactions[i].Currency))
+	}
+	for i := range req.BankTransactions {
+		req.BankTransactions[i].Currency = strings.ToUpper(strings.TrimSpace(req.BankTransactions[i].Currency))
+	}
+}
+
+// transactions converts the request transactions, reading amounts in their
+// currency, else in currency.
+func (req ReconcileRequest) transactions(currency string) ([]reconciliation.SystemTransaction, []reconciliation.BankTransaction, error) {
+	system := make([]reconciliation.SystemTransaction, len(req.SystemTransactions))
+	for i, t := range req.SystemTransactions {
+		trxCurrency := t.Currency
+		if trxCurrency == "" {
+			trxCurrency = currency
+		}
+		amount, err := reconciliation.ParseCurrencyMoney(t.Amount.String(), trxCurrency, reconciliation.AmountFormat{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid system_transactions[%d].amount (%v)", i, err)
+		}
+		system[i] = reconciliation.SystemTransaction{
+			TransactionID:   t.TransactionID,
+			Amount:          amount,
+			Currency:        trxCurrency,
+			Type:            reconciliation.TransactionType(t.Type),
+			TransactionTime: t.TransactionTime,
+		}
+	}
+
+	bank := make([]reconciliation.BankTransaction, len(req.BankTransactions))
+	for i, t := range req.BankTransactions {
+		trxCurrency := t.Currency
+		if trxCurrency == "" {
+			trxCurrency = currency
+		}
+		amount, err := reconciliation.ParseCurrencyMoney(t.Amount.String(), trxCurrency, reconciliation.AmountFormat{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid bank_transactions[%d].amount (%v)", i, err)
+		}
+		date, _ := time.Parse(reconciliation.BankTimeFormat, t.Date)
+		bank[i] = reconciliation.BankTransaction{
+			BankName:    t.BankName,
+			UniqueID:    t.UniqueID,
+			Amount:      amount,
+			Currency:    trxCurrency,
+			Date:        date,
+			Description: t.Description,
+			Reference:   t.Reference,
+		}
+	}
+	return system, bank, nil
+}
+
+// parseReconcileOptions reads the optional matching parameters from the request form.
+func parseReconcileOptions(r *http.Request) (opts reconciliation.ReconcileOptions, err error) {
+	var tol toleranceParam
+
+	if v := r.FormValue("tolerance_amount"); v != "" {
+		if tol.Amount, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, fmt.Errorf("invalid tolerance_amount (expected number)")
+		}
+	}
+	if v := r.FormValue("tolerance_percent"); v != "" {
+		if tol.Percent, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, fmt.Errorf("invalid tolerance_percent (expected number)")
+		}
+	}
+	if opts.Tolerance, err = tol.toTolerance("tolerance"); err != nil {
+		return opts, err
+	}
+
+	var fxTol toleranceParam
+	if v := r.FormValue("fx_tolerance_amount"); v != "" {
+		if fxTol.Amount, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, fmt.Errorf("invalid fx_tolerance_amount (expected number)")
+		}
+	}
+	if v := r.FormValue("fx_tolerance_percent"); v != "" {
+		if fxTol.Percent, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, fmt.Errorf("invalid fx_tolerance_percent (expected number)")
+		}
+	}
+	if opts.FXTolerance, err = fxTol.toTolerance("fx_tolerance"); err != nil {
+		return opts, err
+	}
+
+	if v := r.FormValue("bank_tolerances"); v != "" {
+		var banks map[string]toleranceParam
+		if err = json.Unmarshal([]byte(v), &banks); err != nil {
+			return opts, fmt.Errorf("invalid bank_tolerances (expected JSON object)")
+		}
+
+		opts.BankTolerances = make(map[string]reconciliation.Tolerance, len(banks))
+		for bank, p := range banks {
+			if opts.BankTolerances[bank], err = p.toTolerance("bank_tolerances"); err != nil {
+				return opts, err
+			}
+		}
+	}
+
+	if v := r.FormValue("settlement_days"); v != "" {
+		if opts.Window.MaxDays, err = strconv.Atoi(v); err != nil || opts.Window.MaxDays < 0 || opts.Window.MaxDays > reconciliation.MaxSettlementDays {
+			return opts, fmt.Errorf("invalid settlement_days (expected integer between 0 and %d)", reconciliation.MaxSettlementDays)
+		}
+	}
+	if v := r.FormValue("skip_weekends"); v != "" {
+		if opts.Window.SkipWeekends, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("invalid skip_weekends (expected boolean)")
+		}
+	}
+	if v := r.FormValue("holidays"); v != "" {
+		for _, d := range strings.Split(v, ",") {
+			holiday, err := time.Parse("2006-01-02", strings.TrimSpace(d))
+			if err != nil {
+				return opts, fmt.Errorf("invalid holidays (expected comma separated YYYY-MM-DD)")
+			}
+			opts.Window.Holidays = append(opts.Window.Holidays, holiday)
+		}
+	}
+
+	if v := r.FormValue("max_group_size"); v != "" {
+		if opts.MaxGroupSize, err = strconv.Atoi(v); err != nil || opts.MaxGroupSize < 0 || opts.MaxGroupSize > reconciliation.MaxSplitGroupSize {
+			return opts, fmt.Errorf("invalid max_group_size (expected integer between 0 and %d)", reconciliation.MaxSplitGroupSize)
+		}
+	}
+
+	if v := r.FormValue("min_confidence"); v != "" {
+		if opts.MinConfidence, err = strconv.ParseFloat(v, 64); err != nil || opts.MinConfidence < 0 || opts.MinConfidence > 1 {
+			return opts, fmt.Errorf("invalid min_confidence (expected number between 0 and 1)")
+		}
+	}
+
+	switch mode := reconciliation.MatchMode(r.FormValue("match_mode")); mode {
+	case "", reconciliation.MatchModeGreedy, reconciliation.MatchModeOptimal:
+		opts.Mode = mode
+	default:
+		return opts, fmt.Errorf("invalid match_mode (expected greedy or optimal)")
+	}
+
+	ref := referenceParam{Column: r.FormValue("reference_column"), Pattern: r.FormValue("reference_pattern")}
+	if opts.Reference, err = ref.toReferenceRule("reference_pattern"); err != nil {
+		return opts, err
+	}
+
+	if v := r.FormValue("bank_references"); v != "" {
+		var banks map[string]referenceParam
+		if err = json.Unmarshal([]byte(v), &banks); err != nil {
+			return opts, fmt.Errorf("invalid bank_references (expected JSON object)")
+		}
+
+		opts.BankReferences = make(map[string]reconciliation.ReferenceRule, len(banks))
+		for bank, p := range banks {
+			if opts.BankReferences[bank], err = p.toReferenceRule("bank_references"); err != nil {
+				return opts, err
+			}
+		}
+	}
+
+	if v := r.FormValue("strict"); v != "" {
+		if opts.Strict, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("invalid strict (expected boolean)")
+		}
+	}
+
+	if v := r.FormValue("currency"); v != "" {
+		opts.Currency = strings.ToUpper(strings.TrimSpace(v))
+		if !reconciliation.IsCurrencyCode(opts.Currency) {
+			return opts, fmt.Errorf("invalid currency (expected ISO 4217 code)")
+		}
+	}
+
+	opts.SystemProfile = r.FormValue("system_profile")
+	for _, v := range r.Form["bank_profile"] {
+		opts.BankProfiles = append(opts.BankProfiles, strings.TrimSpace(v))
+	}
+	for _, v := range r.Form["bank_format"] {
+		format, err := reconciliation.ParseBankFormat(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.BankFormats = append(opts.BankFormats, format)
+	}
+
+	opts.SheetName = strings.TrimSpace(r.FormValue("sheet_name"))
+	if v := r.FormValue("header_row"); v != "" {
+		if opts.HeaderRow, err = strconv.Atoi(v); err != nil || opts.HeaderRow < 1 {
+			return opts, fmt.Errorf("invalid header_row (expected positive integer)")
+		}
+	}
+
+	return opts, nil
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/elkoshar/reconciliation-app/pkg/response"
 	"github.com/elkoshar/reconciliation-app/service/reconciliation"
@@ -22,11 +23,23 @@ type MockReconciliationService struct {
 	mock.Mock
 }
 
-func (m *MockReconciliationService) Reconcile(startDate string, endDate string, sysData io.Reader, attachment *multipart.Form, systemTransactions []reconciliation.SystemTransaction, bankTransactions []reconciliation.BankTransaction) (reconciliation.ReconciliationResult, error) {
-	args := m.Called(startDate, endDate, sysData, attachment, systemTransactions, bankTransactions)
+func (m *MockReconciliationService) Reconcile(startDate string, endDate string, sysData io.Reader, attachment *multipart.Form, systemTransactions []reconciliation.SystemTransaction, bankTransactions []reconciliation.BankTransaction, opts reconciliation.ReconcileOptions) (reconciliation.ReconciliationResult, error) {
+	args := m.Called(startDate, endDate, sysData, attachment, systemTransactions, bankTransactions, opts)
 	return args.Get(0).(reconciliation.ReconciliationResult), args.Error(1)
 }
 
+func (m *MockReconciliationService) Rules() []reconciliation.Rule {
+	args := m.Called()
+	rules, _ := args.Get(0).([]reconciliation.Rule)
+	return rules
+}
+
+func (m *MockReconciliationService) NewStream(startDate string, endDate string, opts reconciliation.ReconcileOptions) (*reconciliation.Stream, error) {
+	args := m.Called(startDate, endDate, opts)
+	stream, _ := args.Get(0).(*reconciliation.Stream)
+	return stream, args.Error(1)
+}
+
 func TestInit(t *testing.T) {
 	mockService := new(MockReconciliationService)
 	Init(mockService)
@@ -54,6 +67,7 @@ func TestReconciliation_Success(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(expectedResult, nil)
 
 	// Create multipart form request
@@ -150,6 +164,7 @@ func TestReconciliation_ServiceError(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(reconciliation.ReconciliationResult{}, errors.New("service error"))
 
 	// Create valid multipart form request
@@ -192,6 +207,7 @@ func TestReconciliation_InvalidDateFormat(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(reconciliation.ReconciliationResult{}, errors.New("invalid start_date (expected YYYY-MM-DD)"))
 
 	body := &bytes.Buffer{}
@@ -242,6 +258,7 @@ func TestReconciliation_MultipleBankFiles(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(expectedResult, nil)
 
 	body := &bytes.Buffer{}
@@ -291,6 +308,7 @@ func TestReconciliation_EmptyFormValues(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(reconciliation.ReconciliationResult{}, errors.New("invalid start_date (expected YYYY-MM-DD)"))
 
 	body := &bytes.Buffer{}
@@ -335,6 +353,7 @@ func TestReconciliation_LargeFile(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(expectedResult, nil)
 
 	body := &bytes.Buffer{}
@@ -369,3 +388,462 @@ func TestReconciliation_LargeFile(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+func TestReconciliation_StrictModeError(t *testing.T) {
+	mockService := new(MockReconciliationService)
+	Init(mockService)
+
+	parseErrors := []reconciliation.ParseError{
+		{File: "bank.csv", Line: 3, Column: "date", Value: "yesterday", Reason: "invalid date (expected layout 2006-01-02)"},
+	}
+	mockService.On("Reconcile",
+		"2025-01-01",
+		"2025-01-31",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.MatchedBy(func(opts reconciliation.ReconcileOptions) bool { return opts.Strict }),
+	).Return(reconciliation.ReconciliationResult{}, &reconciliation.StrictModeError{Errors: parseErrors})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("start_date", "2025-01-01")
+	writer.WriteField("end_date", "2025-01-31")
+	writer.WriteField("strict", "true")
+
+	systemPart, err := writer.CreateFormFile("system_data", "system.csv")
+	assert.NoError(t, err)
+	systemPart.Write([]byte("trx_id,amount,type,timestamp"))
+
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/reconciliation", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	Reconciliation(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var resp struct {
+		Data  []reconciliation.ParseError `json:"data"`
+		Error response.Error              `json:"error"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.True(t, resp.Error.Status)
+	assert.Equal(t, parseErrors, resp.Data)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestReconciliation_InvalidTolerance(t *testing.T) {
+	mockService := new(MockReconciliationService)
+	Init(mockService)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("start_date", "2025-01-01")
+	writer.WriteField("end_date", "2025-01-31")
+	writer.WriteField("tolerance_amount", "abc")
+
+	systemPart, err := writer.CreateFormFile("system_data", "system.csv")
+	assert.NoError(t, err)
+	systemPart.Write([]byte("trx_id,amount,type,timestamp"))
+
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/reconciliation", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	Reconciliation(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp response.Response
+	err = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.True(t, resp.Error.Status)
+	assert.Contains(t, resp.Error.Msg, "tolerance_amount")
+
+	mockService.AssertNotCalled(t, "Reconcile")
+}
+
+func TestParseReconcileOptions(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("tolerance_amount", "5.5")
+	writer.WriteField("tolerance_percent", "1")
+	writer.WriteField("bank_tolerances", `{"Bank A":{"amount":10,"percent":0.5}}`)
+	writer.WriteField("settlement_days", "2")
+	writer.WriteField("skip_weekends", "true")
+	writer.WriteField("holidays", "2025-12-25, 2025-12-26")
+	writer.WriteField("max_group_size", "3")
+	writer.WriteField("match_mode", "optimal")
+	writer.WriteField("min_confidence", "0.75")
+	writer.WriteField("reference_column", "description")
+	writer.WriteField("bank_references", `{"Bank A":{"column":"remark","pattern":"TRX(\\d+)"}}`)
+	writer.WriteField("strict", "1")
+	writer.WriteField("currency", "idr")
+	writer.WriteField("system_profile", "ledger")
+	writer.WriteField("bank_profile", "bca")
+	writer.WriteField("bank_profile", "mandiri")
+	writer.WriteField("fx_tolerance_amount", "2")
+	writer.WriteField("fx_tolerance_percent", "0.5")
+	writer.WriteField("bank_format", "CSV")
+	writer.WriteField("bank_format", "mt940")
+	writer.WriteField("sheet_name", " Transactions ")
+	writer.WriteField("header_row", "3")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/reconciliation", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	opts, err := parseReconcileOptions(req)
+	assert.NoError(t, err)
+	assert.Equal(t, reconciliation.Tolerance{Amount: 550, Percent: 1}, opts.Tolerance)
+	assert.Equal(t, reconciliation.Tolerance{Amount: 1000, Percent: 0.5}, opts.BankTolerances["Bank A"])
+	assert.Equal(t, 2, opts.Window.MaxDays)
+	assert.True(t, opts.Window.SkipWeekends)
+	assert.Len(t, opts.Window.Holidays, 2)
+	assert.Equal(t, 3, opts.MaxGroupSize)
+	assert.Equal(t, reconciliation.MatchModeOptimal, opts.Mode)
+	assert.Equal(t, 0.75, opts.MinConfidence)
+	assert.Equal(t, reconciliation.ReferenceRule{Column: "description"}, opts.Reference)
+	assert.Equal(t, reconciliation.ReferenceRule{Column: "remark", Pattern: `TRX(\d+)`}, opts.BankReferences["Bank A"])
+	assert.True(t, opts.Strict)
+	assert.Equal(t, "IDR", opts.Currency)
+	assert.Equal(t, "ledger", opts.SystemProfile)
+	assert.Equal(t, []string{"bca", "mandiri"}, opts.BankProfiles)
+	assert.Equal(t, reconciliation.Tolerance{Amount: 200, Percent: 0.5}, opts.FXTolerance)
+	assert.Equal(t, []reconciliation.BankFormat{reconciliation.BankFormatCSV, reconciliation.BankFormatMT940}, opts.BankFormats)
+	assert.Equal(t, "Transactions", opts.SheetName)
+	assert.Equal(t, 3, opts.HeaderRow)
+}
+
+func TestReconciliationStream(t *testing.T) {
+	newRequest := func(t *testing.T, fields [][2]string, files [][3]string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, f := range fields {
+			writer.WriteField(f[0], f[1])
+		}
+		for _, f := range files {
+			part, err := writer.CreateFormFile(f[0], f[1])
+			assert.NoError(t, err)
+			part.Write([]byte(f[2]))
+		}
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/reconciliation/stream", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+	dates := [][2]string{{"start_date", "2025-01-15"}, {"end_date", "2025-01-15"}}
+	systemFile := [3]string{"system_data", "system.csv", "trx_id,amount,type,timestamp\nTRX001,100.50,CREDIT,2025-01-15 10:30:00"}
+	bankFile := [3]string{"bank_csv", "bank.csv", "unique_id,amount,date\nBANK001,100.50,2025-01-15"}
+
+	t.Run("success", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		stream, err := reconciliation.NewReconciliationService().NewStream("2025-01-15", "2025-01-15", reconciliation.ReconcileOptions{})
+		assert.NoError(t, err)
+		mockService.On("NewStream", "2025-01-15", "2025-01-15", mock.Anything).Return(stream, nil)
+
+		w := httptest.NewRecorder()
+		ReconciliationStream(w, newRequest(t, dates, [][3]string{systemFile, bankFile}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				TotalMatched int
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Data.TotalMatched)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("missing system data", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+
+		w := httptest.NewRecorder()
+		ReconciliationStream(w, newRequest(t, dates, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "no such file")
+	})
+
+	t.Run("field after file", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		stream, err := reconciliation.NewReconciliationService().NewStream("2025-01-15", "2025-01-15", reconciliation.ReconcileOptions{})
+		assert.NoError(t, err)
+		mockService.On("NewStream", "2025-01-15", "2025-01-15", mock.Anything).Return(stream, nil)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("start_date", "2025-01-15")
+		writer.WriteField("end_date", "2025-01-15")
+		part, _ := writer.CreateFormFile("system_data", "system.csv")
+		part.Write([]byte(systemFile[2]))
+		writer.WriteField("strict", "true")
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/reconciliation/stream", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		ReconciliationStream(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `form field \"strict\" must precede the uploaded files`)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		mockService.On("NewStream", "2025-01-15", "2025-01-15", mock.Anything).Return(nil, errors.New("invalid end_date"))
+
+		w := httptest.NewRecorder()
+		ReconciliationStream(w, newRequest(t, dates, [][3]string{systemFile}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not multipart", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/reconciliation/stream", strings.NewReader("invalid data"))
+		req.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+
+		ReconciliationStream(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestReconciliationJSON(t *testing.T) {
+	newRequest := func(target, contentType, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+	validBody := `{
+		"start_date": "2025-01-15",
+		"end_date": "2025-01-16",
+		"system_transactions": [
+			{"trx_id": "TRX001", "amount": 100.5, "type": "CREDIT", "transaction_time": "2025-01-15T10:30:00+07:00"},
+			{"trx_id": "TRX002", "amount": "1500", "currency": "JPY", "type": "DEBIT", "transaction_time": "2025-01-16T09:00:00Z"}
+		],
+		"bank_transactions": [
+			{"bank_name": "BCA", "unique_id": "BK0001", "amount": "100.50", "date": "2025-01-15", "reference": "TRX001"}
+		]
+	}`
+
+	t.Run("success", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		wantSystem := []reconciliation.SystemTransaction{
+			{TransactionID: "TRX001", Amount: 10050, Currency: "USD", Type: reconciliation.Credit, TransactionTime: time.Date(2025, 1, 15, 10, 30, 0, 0, time.FixedZone("", 7*60*60))},
+			{TransactionID: "TRX002", Amount: 1500, Currency: "JPY", Type: reconciliation.Debit, TransactionTime: time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		}
+		wantBank := []reconciliation.BankTransaction{
+			{BankName: "BCA", UniqueID: "BK0001", Amount: 10050, Currency: "USD", Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), Reference: "TRX001"},
+		}
+		mockService.On("Reconcile", "2025-01-15", "2025-01-16", nil, mock.Anything, mock.Anything, wantBank, mock.MatchedBy(func(opts reconciliation.ReconcileOptions) bool {
+			return opts.Currency == "USD" && opts.Window.MaxDays == 1
+		})).Return(reconciliation.ReconciliationResult{TotalMatched: 1}, nil).Run(func(args mock.Arguments) {
+			system := args.Get(4).([]reconciliation.SystemTransaction)
+			assert.Len(t, system, len(wantSystem))
+			for i := range system {
+				assert.True(t, wantSystem[i].TransactionTime.Equal(system[i].TransactionTime))
+				system[i].TransactionTime = wantSystem[i].TransactionTime
+			}
+			assert.Equal(t, wantSystem, system)
+		})
+
+		w := httptest.NewRecorder()
+		ReconciliationJSON(w, newRequest("/reconciliation/json?currency=usd&settlement_days=1", "application/json; charset=utf-8", validBody))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				TotalMatched int
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.Data.TotalMatched)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("lowercase currency", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		body := `{
+			"start_date": "2025-01-15",
+			"end_date": "2025-01-15",
+			"system_transactions": [{"trx_id": "TRX001", "amount": "1500", "currency": "jpy", "type": "CREDIT", "transaction_time": "2025-01-15T09:00:00Z"}],
+			"bank_transactions": [{"bank_name": "BCA", "unique_id": "BK0001", "amount": "1500", "currency": " Jpy ", "date": "2025-01-15"}]
+		}`
+		mockService.On("Reconcile", "2025-01-15", "2025-01-15", nil, mock.Anything, mock.MatchedBy(func(system []reconciliation.SystemTransaction) bool {
+			return len(system) == 1 && system[0].Currency == "JPY" && system[0].Amount == 1500
+		}), mock.MatchedBy(func(bank []reconciliation.BankTransaction) bool {
+			return len(bank) == 1 && bank[0].Currency == "JPY" && bank[0].Amount == 1500
+		}), mock.Anything).Return(reconciliation.ReconciliationResult{TotalMatched: 1}, nil)
+
+		w := httptest.NewRecorder()
+		ReconciliationJSON(w, newRequest("/reconciliation/json", "application/json", body))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	for _, tt := range []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		wantCode    int
+		wantMsg     string
+	}{
+		{
+			name:        "not json",
+			contentType: "text/csv",
+			body:        "trx_id,amount\n",
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantMsg:     `unsupported content type "text/csv" (expected application/json)`,
+		},
+		{
+			name:     "malformed body",
+			body:     `{"start_date": `,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "invalid JSON body: unexpected EOF",
+		},
+		{
+			name:     "missing transaction ID",
+			body:     `{"start_date": "2025-01-15", "end_date": "2025-01-16", "system_transactions": [{"amount": 1, "type": "CREDIT", "transaction_time": "2025-01-15T10:30:00Z"}]}`,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "Error:Field validation for 'TransactionID' failed on the 'required' tag",
+		},
+		{
+			name:     "invalid bank date",
+			body:     `{"start_date": "2025-01-15", "end_date": "2025-01-16", "bank_transactions": [{"bank_name": "BCA", "unique_id": "B1", "amount": 1, "date": "15/01/2025"}]}`,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "Error:Field validation for 'Date' failed on the 'datetime' tag",
+		},
+		{
+			name:     "invalid type",
+			body:     `{"start_date": "2025-01-15", "end_date": "2025-01-16", "system_transactions": [{"trx_id": "T1", "amount": 1, "type": "credit", "transaction_time": "2025-01-15T10:30:00Z"}]}`,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "Error:Field validation for 'Type' failed on the 'oneof' tag",
+		},
+		{
+			name:     "amount out of range",
+			body:     `{"start_date": "2025-01-15", "end_date": "2025-01-16", "bank_transactions": [{"bank_name": "BCA", "unique_id": "B1", "amount": "99999999999999999999", "date": "2025-01-15"}]}`,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "invalid bank_transactions[0].amount",
+		},
+		{
+			name:     "invalid option",
+			target:   "/reconciliation/json?settlement_days=-1",
+			body:     validBody,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "invalid settlement_days (expected integer between 0 and 31)",
+		},
+		{
+			name:     "group size too large",
+			target:   "/reconciliation/json?max_group_size=25",
+			body:     validBody,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "invalid max_group_size (expected integer between 0 and 5)",
+		},
+		{
+			name:     "settlement window too long",
+			target:   "/reconciliation/json?settlement_days=100000000",
+			body:     validBody,
+			wantCode: http.StatusBadRequest,
+			wantMsg:  "invalid settlement_days (expected integer between 0 and 31)",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockReconciliationService)
+			Init(mockService)
+			if tt.target == "" {
+				tt.target = "/reconciliation/json"
+			}
+			if tt.contentType == "" {
+				tt.contentType = "application/json"
+			}
+
+			w := httptest.NewRecorder()
+			ReconciliationJSON(w, newRequest(tt.target, tt.contentType, tt.body))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			var resp response.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Contains(t, resp.Error.Msg, tt.wantMsg)
+			mockService.AssertNotCalled(t, "Reconcile")
+		})
+	}
+
+	t.Run("service error", func(t *testing.T) {
+		mockService := new(MockReconciliationService)
+		Init(mockService)
+		mockService.On("Reconcile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(reconciliation.ReconciliationResult{}, errors.New("invalid start_date (expected YYYY-MM-DD)"))
+
+		w := httptest.NewRecorder()
+		ReconciliationJSON(w, newRequest("/reconciliation/json", "application/json", validBody))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestRules(t *testing.T) {
+	mockService := new(MockReconciliationService)
+	Init(mockService)
+
+	rules := []reconciliation.Rule{
+		{
+			Name:      "bca_half_percent",
+			Bank:      "BCA",
+			Tolerance: reconciliation.Tolerance{Percent: 0.5},
+		},
+	}
+	mockService.On("Rules").Return(rules)
+
+	req := httptest.NewRequest(http.MethodGet, "/reconciliation/rules", nil)
+	w := httptest.NewRecorder()
+
+	Rules(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data, 1)
+	assert.Equal(t, "bca_half_percent", resp.Data[0]["Name"])
+	assert.Equal(t, "BCA", resp.Data[0]["Bank"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRules_Empty(t *testing.T) {
+	mockService := new(MockReconciliationService)
+	Init(mockService)
+
+	mockService.On("Rules").Return(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/reconciliation/rules", nil)
+	w := httptest.NewRecorder()
+
+	Rules(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
+}
@@ -70,6 +70,9 @@ func handler(cfg *config.Config) http.Handler {
 			// reconciliation group
 			r.Route("/reconciliation", func(r chi.Router) {
 				r.Post("/", reconciliation.Reconciliation)
+				r.Post("/stream", reconciliation.ReconciliationStream)
+				r.Post("/json", reconciliation.ReconciliationJSON)
+				r.Get("/rules", reconciliation.Rules)
 			})
 
 		})
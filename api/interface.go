@@ -8,5 +8,7 @@ import (
 )
 
 type ReconciliationService interface {
-	Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []reconciliation.SystemTransaction, bankTransactions []reconciliation.BankTransaction) (reconciliation.ReconciliationResult, error)
+	Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []reconciliation.SystemTransaction, bankTransactions []reconciliation.BankTransaction, opts reconciliation.ReconcileOptions) (reconciliation.ReconciliationResult, error)
+	Rules() []reconciliation.Rule
+	NewStream(startDate string, endDate string, opts reconciliation.ReconcileOptions) (*reconciliation.Stream, error)
 }
@@ -36,3 +36,38 @@ func testError(t *testing.T) {
 	)
 	assert.Error(t, err)
 }
+
+func TestLoadMatchingRules(t *testing.T) {
+	rules, err := LoadMatchingRules("../configs/sample-matching-rules.yaml")
+	assert.NoError(t, err)
+	assert.Len(t, rules.Rules, 2)
+	assert.Equal(t, "bca_same_day_half_percent", rules.Rules[0].Name)
+	assert.Equal(t, "BCA", rules.Rules[0].Bank)
+	assert.Equal(t, 0.5, rules.Rules[0].AmountTolerancePercent)
+	assert.Equal(t, 2, rules.Rules[1].SettlementDays)
+	assert.True(t, rules.Rules[1].SkipWeekends)
+	assert.Len(t, rules.Fees, 2)
+	assert.Equal(t, 6500.0, rules.Fees[0].Amount)
+	assert.Equal(t, "(?i)biaya|admin fee", rules.Fees[1].Pattern)
+
+	_, err = LoadMatchingRules("notfound/rules.yaml")
+	assert.Error(t, err)
+}
+
+func TestLoadProfiles(t *testing.T) {
+	profiles, err := LoadProfiles("../configs/sample-profiles.yaml")
+	assert.NoError(t, err)
+	assert.Len(t, profiles.BankProfiles, 2)
+	assert.Equal(t, "bca", profiles.BankProfiles[0].Name)
+	assert.Equal(t, ";", profiles.BankProfiles[0].Delimiter)
+	assert.Equal(t, "BCA_Statement*", profiles.BankProfiles[0].FilenamePattern)
+	assert.Equal(t, "dr_cr_flag", profiles.BankProfiles[0].AmountSign)
+	assert.Equal(t, "DB/CR", profiles.BankProfiles[0].Columns.DrCr)
+	assert.Equal(t, "Debit", profiles.BankProfiles[1].Columns.Debit)
+	assert.Len(t, profiles.SystemProfiles, 1)
+	assert.Equal(t, "booked_at", profiles.SystemProfiles[0].Columns.TransactionTime)
+	assert.Equal(t, []string{"unix_ms"}, profiles.SystemProfiles[0].TimeLayouts)
+
+	_, err = LoadProfiles("notfound/profiles.yaml")
+	assert.Error(t, err)
+}
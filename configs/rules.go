@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// LoadMatchingRules reads the declarative matching rules from a YAML or JSON
+// file. The file type is taken from the file extension.
+func LoadMatchingRules(path string) (*MatchingRules, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read matching rules file: %v", err)
+	}
+
+	rules := new(MatchingRules)
+	if err := v.UnmarshalExact(rules); err != nil {
+		return nil, fmt.Errorf("failed to parse matching rules file: %v", err)
+	}
+
+	return rules, nil
+}
+
+// LoadProfiles reads the bank statement and system export layout profiles
+// from a YAML or JSON file. The file type is taken from the file extension.
+func LoadProfiles(path string) (*Profiles, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %v", err)
+	}
+
+	profiles := new(Profiles)
+	if err := v.UnmarshalExact(profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles file: %v", err)
+	}
+
+	return profiles, nil
+}
@@ -17,5 +17,97 @@ type (
 		HTTPMaxIdleConnections        int           `mapstructure:"HTTP_MAX_IDLE_CONNECTIONS"`
 		HTTPMaxIdleConnectionsPerHost int           `mapstructure:"HTTP_MAX_IDLE_CONNECTIONS_PER_HOST"`
 		HTTPIdleConnectionTimeout     time.Duration `mapstructure:"HTTP_IDLE_CONNECTION_TIMEOUT"`
+		MatchingRulesFile             string        `mapstructure:"MATCHING_RULES_FILE"`
+		ProfilesFile                  string        `mapstructure:"PROFILES_FILE"`
+		FXRatesFile                   string        `mapstructure:"FX_RATES_FILE"`
+		SystemTimezone                string        `mapstructure:"SYSTEM_TIMEZONE"`
+		BankTimezone                  string        `mapstructure:"BANK_TIMEZONE"`
+		FilterTimezone                string        `mapstructure:"FILTER_TIMEZONE"`
+		ReconcileWorkers              int           `mapstructure:"RECONCILE_WORKERS"`
+	}
+
+	// MatchingRules will holds the declarative matching rules file content
+	MatchingRules struct {
+		Rules []MatchingRule `mapstructure:"rules"`
+		Fees  []FeeRule      `mapstructure:"fees"`
+	}
+
+	// MatchingRule will holds one ordered matching rule
+	MatchingRule struct {
+		Name                   string   `mapstructure:"name"`
+		Bank                   string   `mapstructure:"bank"`
+		SettlementDays         int      `mapstructure:"settlement_days"`
+		SkipWeekends           bool     `mapstructure:"skip_weekends"`
+		Holidays               []string `mapstructure:"holidays"`
+		AmountTolerance        float64  `mapstructure:"amount_tolerance"`
+		AmountTolerancePercent float64  `mapstructure:"amount_tolerance_percent"`
+	}
+
+	// FeeRule will holds one bank fee rule
+	FeeRule struct {
+		Name    string  `mapstructure:"name"`
+		Bank    string  `mapstructure:"bank"`
+		Amount  float64 `mapstructure:"amount"`
+		Percent float64 `mapstructure:"percent"`
+		Pattern string  `mapstructure:"pattern"`
+	}
+
+	// Profiles will holds the file layout profiles file content
+	Profiles struct {
+		BankProfiles   []BankProfile   `mapstructure:"bank_profiles"`
+		SystemProfiles []SystemProfile `mapstructure:"system_profiles"`
+	}
+
+	// BankProfile will holds the layout of one bank statement export
+	BankProfile struct {
+		Name               string      `mapstructure:"name"`
+		BankName           string      `mapstructure:"bank_name"`
+		FilenamePattern    string      `mapstructure:"filename_pattern"`
+		Currency           string      `mapstructure:"currency"`
+		Delimiter          string      `mapstructure:"delimiter"`
+		Columns            BankColumns `mapstructure:"columns"`
+		DateLayout         string      `mapstructure:"date_layout"`
+		DecimalSeparator   string      `mapstructure:"decimal_separator"`
+		ThousandsSeparator string      `mapstructure:"thousands_separator"`
+		Rounding           string      `mapstructure:"rounding"`
+		AmountSign         string      `mapstructure:"amount_sign"`
+		DebitFlag          string      `mapstructure:"debit_flag"`
+		CreditFlag         string      `mapstructure:"credit_flag"`
+		Timezone           string      `mapstructure:"timezone"`
+	}
+
+	// BankColumns will holds the bank statement column mapping
+	BankColumns struct {
+		UniqueID    string `mapstructure:"unique_id"`
+		Amount      string `mapstructure:"amount"`
+		Debit       string `mapstructure:"debit"`
+		Credit      string `mapstructure:"credit"`
+		DrCr        string `mapstructure:"dr_cr"`
+		Date        string `mapstructure:"date"`
+		Description string `mapstructure:"description"`
+		Currency    string `mapstructure:"currency"`
+	}
+
+	// SystemProfile will holds the layout of a system transaction export
+	SystemProfile struct {
+		Name               string        `mapstructure:"name"`
+		Currency           string        `mapstructure:"currency"`
+		Delimiter          string        `mapstructure:"delimiter"`
+		Columns            SystemColumns `mapstructure:"columns"`
+		TimeLayout         string        `mapstructure:"time_layout"`
+		TimeLayouts        []string      `mapstructure:"time_layouts"`
+		DecimalSeparator   string        `mapstructure:"decimal_separator"`
+		ThousandsSeparator string        `mapstructure:"thousands_separator"`
+		Rounding           string        `mapstructure:"rounding"`
+		Timezone           string        `mapstructure:"timezone"`
+	}
+
+	// SystemColumns will holds the system export column mapping
+	SystemColumns struct {
+		TransactionID   string `mapstructure:"transaction_id"`
+		Amount          string `mapstructure:"amount"`
+		Type            string `mapstructure:"type"`
+		TransactionTime string `mapstructure:"transaction_time"`
+		Currency        string `mapstructure:"currency"`
 	}
 )
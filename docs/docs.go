@@ -59,16 +59,305 @@ const docTemplate = `{
                     },
                     {
                         "type": "file",
-                        "description": "system data file upload",
+                        "description": "system data file upload, CSV or XLSX",
                         "name": "system_data",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "file",
-                        "description": "bank CSV file upload",
+                        "description": "bank statement file upload, CSV, XLSX, MT940, camt.053/camt.054 XML, OFX/QFX or BAI2",
                         "name": "bank_csv",
                         "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "example": 500,
+                        "description": "absolute amount tolerance for near-matches",
+                        "name": "tolerance_amount",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "example": 0.5,
+                        "description": "percentage amount tolerance for near-matches",
+                        "name": "tolerance_percent",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "{\"Stmt-BCA.csv\":{\"amount\":500,\"percent\":0}}",
+                        "description": "per bank tolerance as JSON object keyed by bank name",
+                        "name": "bank_tolerances",
+                        "in": "formData"
+                    },
+                    {
+                        "maximum": 31,
+                        "minimum": 0,
+                        "type": "integer",
+                        "example": 2,
+                        "description": "number of settlement days a bank line may be posted after the system transaction",
+                        "name": "settlement_days",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "do not count weekends as settlement days",
+                        "name": "skip_weekends",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-12-25,2025-12-26",
+                        "description": "comma separated holiday dates format YYYY-MM-DD",
+                        "name": "holidays",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "example": 0.8,
+                        "description": "matches scoring below this confidence (0 to 1) are reported as unmatched",
+                        "name": "min_confidence",
+                        "in": "formData"
+                    },
+                    {
+                        "maximum": 5,
+                        "minimum": 0,
+                        "type": "integer",
+                        "example": 3,
+                        "description": "maximum number of transactions grouped in a split match, 0 disables split matching",
+                        "name": "max_group_size",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "greedy",
+                            "optimal"
+                        ],
+                        "type": "string",
+                        "default": "greedy",
+                        "description": "discrepancy matching mode",
+                        "name": "match_mode",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "description",
+                        "description": "bank statement column holding our transaction ID",
+                        "name": "reference_column",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "trx-[a-z]+-\\d+",
+                        "description": "regular expression extracting the transaction ID from the reference column",
+                        "name": "reference_pattern",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "{\"Stmt-BCA.csv\":{\"column\":\"remark\",\"pattern\":\"TRX\\d+\"}}",
+                        "description": "per bank reference rule as JSON object keyed by bank name",
+                        "name": "bank_references",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": "default",
+                        "description": "name of the system export profile",
+                        "name": "system_profile",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "name of the statement profile of each bank_csv file in upload order, a single value applies to every file, auto detects the profile, as does empty when named profiles are configured",
+                        "name": "bank_profile",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "enum": [
+                                "csv",
+                                "xlsx",
+                                "mt940",
+                                "camt",
+                                "ofx",
+                                "bai2",
+                                "auto"
+                            ],
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "file format of each bank_csv file in upload order, a single value applies to every file, auto or empty detects the format",
+                        "name": "bank_format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "Sheet1",
+                        "description": "worksheet of XLSX uploads, the first one when empty",
+                        "name": "sheet_name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 1,
+                        "description": "row of XLSX uploads holding the header, counted from one",
+                        "name": "header_row",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "example": "IDR",
+                        "description": "ISO 4217 currency of the transactions whose file does not tell",
+                        "name": "currency",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "fail the request when any row or file cannot be parsed",
+                        "name": "strict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "exchange rates as CSV with date,from,to,rate header or JSON array, overriding the configured rates",
+                        "name": "fx_rates",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "example": 1,
+                        "description": "absolute tolerance between the converted system amount and the bank amount",
+                        "name": "fx_tolerance_amount",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "example": 0.5,
+                        "description": "percentage tolerance between the converted system amount and the bank amount",
+                        "name": "fx_tolerance_percent",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success Response",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_pkg_response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.ReconciliationResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "422": {
+                        "description": "Rejected rows in strict mode",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_pkg_response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.ParseError"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "InternalServerError"
+                    }
+                }
+            }
+        },
+        "/reconciliation/json": {
+            "post": {
+                "description": "ReconciliationJSON reconciles system and bank transactions sent in a JSON body, for services that do not produce files. The optional matching parameters of the reconciliation process are taken from the query string.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reconciliation"
+                ],
+                "summary": "JSON Reconciliation Process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "id",
+                        "description": "accept language",
+                        "name": "Accept-Language",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "period and transactions to reconcile",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api_http_reconciliation.ReconcileRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "example": "IDR",
+                        "description": "ISO 4217 currency of the transactions without one",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "example": 500,
+                        "description": "absolute amount tolerance for near-matches",
+                        "name": "tolerance_amount",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 31,
+                        "minimum": 0,
+                        "type": "integer",
+                        "example": 2,
+                        "description": "number of settlement days a bank line may be posted after the system transaction",
+                        "name": "settlement_days",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "greedy",
+                            "optimal"
+                        ],
+                        "type": "string",
+                        "default": "greedy",
+                        "description": "discrepancy matching mode",
+                        "name": "match_mode",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -93,14 +382,271 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request"
                     },
+                    "415": {
+                        "description": "Unsupported Media Type"
+                    },
                     "500": {
                         "description": "InternalServerError"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/reconciliation/rules": {
+            "get": {
+                "description": "Rules returns the active declarative matching rules",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reconciliation"
+                ],
+                "summary": "Matching Rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "id",
+                        "description": "accept language",
+                        "name": "Accept-Language",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success Response",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_pkg_response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Rule"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/reconciliation/stream": {
+            "post": {
+                "description": "ReconciliationStream reads the upload part by part and spools it to disk by day, so files larger than memory can be reconciled. It takes the same form fields as the reconciliation process, which must all precede the uploaded files. Unlike the reconciliation process, a reference match is only found within the settlement window, and a split match never groups system transactions of different days.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reconciliation"
+                ],
+                "summary": "Streaming Reconciliation Process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "id",
+                        "description": "accept language",
+                        "name": "Accept-Language",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "2023-01-01",
+                        "description": "start date format YYYY-MM-DD",
+                        "name": "start_date",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "2023-01-31",
+                        "description": "end date format YYYY-MM-DD",
+                        "name": "end_date",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "system data file upload, CSV or XLSX",
+                        "name": "system_data",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "bank statement file upload, CSV, XLSX, MT940, camt.053/camt.054 XML, OFX/QFX or BAI2",
+                        "name": "bank_csv",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "exchange rates as CSV with date,from,to,rate header or JSON array, overriding the configured rates",
+                        "name": "fx_rates",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success Response",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_pkg_response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.ReconciliationResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "422": {
+                        "description": "Rejected rows in strict mode",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_pkg_response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.ParseError"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "InternalServerError"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api_http_reconciliation.BankTransactionRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "bank_name",
+                "date",
+                "unique_id"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "-250.00"
+                },
+                "bank_name": {
+                    "type": "string",
+                    "example": "BCA"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "IDR"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2025-01-15"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "TRX001"
+                },
+                "unique_id": {
+                    "type": "string",
+                    "example": "BK0001"
+                }
+            }
+        },
+        "api_http_reconciliation.ReconcileRequest": {
+            "type": "object",
+            "required": [
+                "end_date",
+                "start_date"
+            ],
+            "properties": {
+                "bank_transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api_http_reconciliation.BankTransactionRequest"
+                    }
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-01-31"
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-01-01"
+                },
+                "system_transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api_http_reconciliation.SystemTransactionRequest"
+                    }
+                }
+            }
+        },
+        "api_http_reconciliation.SystemTransactionRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "transaction_time",
+                "trx_id",
+                "type"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "100.50"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "IDR"
+                },
+                "transaction_time": {
+                    "type": "string",
+                    "example": "2025-01-15T10:30:00+07:00"
+                },
+                "trx_id": {
+                    "type": "string",
+                    "example": "TRX001"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "DEBIT",
+                        "CREDIT"
+                    ],
+                    "example": "CREDIT"
+                }
+            }
+        },
         "github_com_elkoshar_reconciliation-app_pkg_response.Error": {
             "type": "object",
             "properties": {
@@ -138,6 +684,27 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.BankFormat": {
+            "type": "string",
+            "enum": [
+                "csv",
+                "mt940",
+                "camt",
+                "ofx",
+                "bai2",
+                "xlsx",
+                "auto"
+            ],
+            "x-enum-varnames": [
+                "BankFormatCSV",
+                "BankFormatMT940",
+                "BankFormatCAMT",
+                "BankFormatOFX",
+                "BankFormatBAI2",
+                "BankFormatXLSX",
+                "BankFormatAuto"
+            ]
+        },
         "github_com_elkoshar_reconciliation-app_service_reconciliation.BankTransaction": {
             "type": "object",
             "properties": {
@@ -148,21 +715,311 @@ const docTemplate = `{
                 "bankName": {
                     "type": "string"
                 },
+                "currency": {
+                    "type": "string"
+                },
                 "date": {
                     "type": "string"
                 },
+                "description": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
                 "uniqueID": {
                     "type": "string"
                 }
             }
         },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.CurrencyTotals": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "totalDiscrepancies": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "totalFXDifferences": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "totalFees": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "totalGroupMatched": {
+                    "type": "integer"
+                },
+                "totalMatched": {
+                    "type": "integer"
+                },
+                "totalUnmatched": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.Discrepancy": {
+            "type": "object",
+            "properties": {
+                "bankAmount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "bankName": {
+                    "type": "string"
+                },
+                "bankUniqueID": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "difference": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "systemAmount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "systemID": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.FXConversion": {
+            "type": "object",
+            "properties": {
+                "convertedAmount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "difference": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "string"
+                },
+                "rateDate": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.FileReport": {
+            "type": "object",
+            "properties": {
+                "bankName": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "fileName": {
+                    "type": "string"
+                },
+                "format": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.BankFormat"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "statements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Statement"
+                    }
+                },
+                "transactions": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.GroupMatch": {
+            "type": "object",
+            "properties": {
+                "bank": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.BankTransaction"
+                    }
+                },
+                "confidence": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "rule": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.MatchRule"
+                },
+                "system": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.SystemTransaction"
+                    }
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.MatchMode": {
+            "type": "string",
+            "enum": [
+                "greedy",
+                "optimal"
+            ],
+            "x-enum-varnames": [
+                "MatchModeGreedy",
+                "MatchModeOptimal"
+            ]
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.MatchRule": {
+            "type": "string",
+            "enum": [
+                "reference",
+                "exact_key",
+                "split",
+                "same_day_discrepancy",
+                "fee",
+                "fx"
+            ],
+            "x-enum-varnames": [
+                "RuleReference",
+                "RuleExactKey",
+                "RuleSplit",
+                "RuleSameDayDiscrepancy",
+                "RuleFee",
+                "RuleFX"
+            ]
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.MatchedPair": {
+            "type": "object",
+            "properties": {
+                "bank": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.BankTransaction"
+                },
+                "confidence": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "fee": {
+                    "type": "integer"
+                },
+                "fx": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.FXConversion"
+                },
+                "rule": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.MatchRule"
+                },
+                "system": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.SystemTransaction"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.ParseError": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
         "github_com_elkoshar_reconciliation-app_service_reconciliation.ReconciliationResult": {
             "type": "object",
             "properties": {
+                "bankTolerances": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Tolerance"
+                    }
+                },
+                "currencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.CurrencyTotals"
+                    }
+                },
+                "discrepancies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Discrepancy"
+                    }
+                },
+                "fees": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.BankTransaction"
+                    }
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.FileReport"
+                    }
+                },
+                "groupMatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.GroupMatch"
+                    }
+                },
+                "matchMode": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.MatchMode"
+                },
+                "matched": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.MatchedPair"
+                    }
+                },
+                "maxGroupSize": {
+                    "type": "integer"
+                },
+                "minConfidence": {
+                    "type": "number",
+                    "format": "float64"
+                },
+                "parseErrors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.ParseError"
+                    }
+                },
+                "settlementWindow": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.SettlementWindow"
+                },
+                "tolerance": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Tolerance"
+                },
                 "totalDiscrepancies": {
                     "type": "integer",
                     "format": "int64"
                 },
+                "totalFees": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "totalGroupMatched": {
+                    "type": "integer"
+                },
                 "totalMatched": {
                     "type": "integer"
                 },
@@ -189,6 +1046,75 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.Rule": {
+            "type": "object",
+            "properties": {
+                "bank": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "tolerance": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.Tolerance"
+                },
+                "window": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.SettlementWindow"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.SettlementWindow": {
+            "type": "object",
+            "properties": {
+                "holidays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "maxDays": {
+                    "type": "integer"
+                },
+                "skipWeekends": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.Statement": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "type": "string"
+                },
+                "closingBalance": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.StatementBalance"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "openingBalance": {
+                    "$ref": "#/definitions/github_com_elkoshar_reconciliation-app_service_reconciliation.StatementBalance"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.StatementBalance": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                }
+            }
+        },
         "github_com_elkoshar_reconciliation-app_service_reconciliation.SystemTransaction": {
             "type": "object",
             "properties": {
@@ -196,6 +1122,9 @@ const docTemplate = `{
                     "type": "integer",
                     "format": "int64"
                 },
+                "currency": {
+                    "type": "string"
+                },
                 "transactionID": {
                     "type": "string"
                 },
@@ -207,6 +1136,19 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_elkoshar_reconciliation-app_service_reconciliation.Tolerance": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "percent": {
+                    "type": "number",
+                    "format": "float64"
+                }
+            }
+        },
         "github_com_elkoshar_reconciliation-app_service_reconciliation.TransactionType": {
             "type": "string",
             "enum": [
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	httpapi "github.com/elkoshar/reconciliation-app/api/http"
 	config "github.com/elkoshar/reconciliation-app/configs"
 	"github.com/elkoshar/reconciliation-app/service/reconciliation"
@@ -9,7 +13,35 @@ import (
 // Init to initiate all DI for service handler implementation
 func InitHttp(config *config.Config) error {
 
-	reconService := reconciliation.NewReconciliationService()
+	rules, feeRules, err := loadMatchingRules(config.MatchingRulesFile)
+	if err != nil {
+		return err
+	}
+
+	bankProfiles, systemProfiles, err := loadProfiles(config.ProfilesFile)
+	if err != nil {
+		return err
+	}
+
+	fxRates, err := loadFXRates(config.FXRatesFile)
+	if err != nil {
+		return err
+	}
+
+	timezones, err := loadTimezones(config)
+	if err != nil {
+		return err
+	}
+
+	reconService := reconciliation.NewReconciliationService(
+		reconciliation.WithRules(rules...),
+		reconciliation.WithFeeRules(feeRules...),
+		reconciliation.WithBankProfiles(bankProfiles...),
+		reconciliation.WithSystemProfiles(systemProfiles...),
+		reconciliation.WithFXRates(fxRates),
+		reconciliation.WithTimezones(timezones),
+		reconciliation.WithWorkers(config.ReconcileWorkers),
+	)
 	httpserver := httpapi.Server{
 		Cfg:   config,
 		Recon: reconService,
@@ -17,3 +49,189 @@ func InitHttp(config *config.Config) error {
 
 	return runHTTPServer(httpserver, config.ServerHttpPort)
 }
+
+// loadMatchingRules reads and validates the declarative matching and fee rules file, if configured
+func loadMatchingRules(path string) ([]reconciliation.Rule, []reconciliation.FeeRule, error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+
+	file, err := config.LoadMatchingRules(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := make([]reconciliation.Rule, 0, len(file.Rules))
+	for _, r := range file.Rules {
+		rule := reconciliation.Rule{
+			Name: r.Name,
+			Bank: r.Bank,
+			Window: reconciliation.SettlementWindow{
+				MaxDays:      r.SettlementDays,
+				SkipWeekends: r.SkipWeekends,
+			},
+			Tolerance: reconciliation.Tolerance{
+				Amount:  reconciliation.ToMoney(r.AmountTolerance),
+				Percent: r.AmountTolerancePercent,
+			},
+		}
+
+		for _, h := range r.Holidays {
+			holiday, err := time.Parse("2006-01-02", h)
+			if err != nil {
+				return nil, nil, fmt.Errorf("rule %q: invalid holiday %q (expected YYYY-MM-DD)", r.Name, h)
+			}
+			rule.Window.Holidays = append(rule.Window.Holidays, holiday)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := reconciliation.ValidateRules(rules); err != nil {
+		return nil, nil, fmt.Errorf("invalid matching rules file: %v", err)
+	}
+
+	feeRules := make([]reconciliation.FeeRule, 0, len(file.Fees))
+	for _, f := range file.Fees {
+		feeRules = append(feeRules, reconciliation.FeeRule{
+			Name:    f.Name,
+			Bank:    f.Bank,
+			Amount:  reconciliation.ToMoney(f.Amount),
+			Percent: f.Percent,
+			Pattern: f.Pattern,
+		})
+	}
+
+	if err := reconciliation.ValidateFeeRules(feeRules); err != nil {
+		return nil, nil, fmt.Errorf("invalid matching rules file: %v", err)
+	}
+
+	return rules, feeRules, nil
+}
+
+// loadProfiles reads and validates the file layout profiles file, if configured
+func loadProfiles(path string) ([]reconciliation.BankProfile, []reconciliation.SystemProfile, error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+
+	file, err := config.LoadProfiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := map[string]bool{}
+	bankProfiles := make([]reconciliation.BankProfile, 0, len(file.BankProfiles))
+	for _, p := range file.BankProfiles {
+		profile := reconciliation.BankProfile{
+			Name:            p.Name,
+			BankName:        p.BankName,
+			FilenamePattern: p.FilenamePattern,
+			Currency:        p.Currency,
+			Delimiter:       p.Delimiter,
+			Columns: reconciliation.BankColumns{
+				UniqueID:    p.Columns.UniqueID,
+				Amount:      p.Columns.Amount,
+				Debit:       p.Columns.Debit,
+				Credit:      p.Columns.Credit,
+				DrCr:        p.Columns.DrCr,
+				Date:        p.Columns.Date,
+				Description: p.Columns.Description,
+				Currency:    p.Columns.Currency,
+			},
+			DateLayout:         p.DateLayout,
+			DecimalSeparator:   p.DecimalSeparator,
+			ThousandsSeparator: p.ThousandsSeparator,
+			Rounding:           reconciliation.RoundingMode(p.Rounding),
+			AmountSign:         reconciliation.AmountSign(p.AmountSign),
+			DebitFlag:          p.DebitFlag,
+			CreditFlag:         p.CreditFlag,
+		}
+		if p.Timezone != "" {
+			if profile.Location, err = reconciliation.LoadTimezone(p.Timezone); err != nil {
+				return nil, nil, fmt.Errorf("invalid profiles file: bank profile %q: %v", p.Name, err)
+			}
+		}
+		if err := profile.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid profiles file: %v", err)
+		}
+		if names[profile.Name] {
+			return nil, nil, fmt.Errorf("invalid profiles file: duplicate bank profile %q", profile.Name)
+		}
+		names[profile.Name] = true
+		bankProfiles = append(bankProfiles, profile)
+	}
+
+	names = map[string]bool{}
+	systemProfiles := make([]reconciliation.SystemProfile, 0, len(file.SystemProfiles))
+	for _, p := range file.SystemProfiles {
+		profile := reconciliation.SystemProfile{
+			Name:      p.Name,
+			Currency:  p.Currency,
+			Delimiter: p.Delimiter,
+			Columns: reconciliation.SystemColumns{
+				TransactionID:   p.Columns.TransactionID,
+				Amount:          p.Columns.Amount,
+				Type:            p.Columns.Type,
+				TransactionTime: p.Columns.TransactionTime,
+				Currency:        p.Columns.Currency,
+			},
+			TimeLayout:         p.TimeLayout,
+			TimeLayouts:        p.TimeLayouts,
+			DecimalSeparator:   p.DecimalSeparator,
+			ThousandsSeparator: p.ThousandsSeparator,
+			Rounding:           reconciliation.RoundingMode(p.Rounding),
+		}
+		if p.Timezone != "" {
+			if profile.Location, err = reconciliation.LoadTimezone(p.Timezone); err != nil {
+				return nil, nil, fmt.Errorf("invalid profiles file: system profile %q: %v", p.Name, err)
+			}
+		}
+		if err := profile.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid profiles file: %v", err)
+		}
+		if names[profile.Name] {
+			return nil, nil, fmt.Errorf("invalid profiles file: duplicate system profile %q", profile.Name)
+		}
+		names[profile.Name] = true
+		systemProfiles = append(systemProfiles, profile)
+	}
+
+	return bankProfiles, systemProfiles, nil
+}
+
+// loadFXRates reads the exchange rates file, if configured
+func loadFXRates(path string) (*reconciliation.FXRates, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fx rates file: %v", err)
+	}
+	defer file.Close()
+
+	rates, err := reconciliation.LoadFXRates(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fx rates file: %v", err)
+	}
+	return rates, nil
+}
+
+// loadTimezones resolves the configured time zones, defaulting to Asia/Jakarta
+func loadTimezones(cfg *config.Config) (tz reconciliation.Timezones, err error) {
+	for _, zone := range []struct {
+		name string
+		loc  **time.Location
+	}{
+		{cfg.SystemTimezone, &tz.System},
+		{cfg.BankTimezone, &tz.Bank},
+		{cfg.FilterTimezone, &tz.Filter},
+	} {
+		if *zone.loc, err = reconciliation.LoadTimezone(zone.name); err != nil {
+			return tz, err
+		}
+	}
+	return tz, nil
+}
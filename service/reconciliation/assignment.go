@@ -0,0 +1,297 @@
+package reconciliation
+
+import (
+	"math"
+	"sort"
+)
+
+// assignOptimal pairs system transactions with bank lines by minimum-cost
+// bipartite assignment. Only bank lines inside the settlement window and
+// within tolerance are candidates. The assignment first maximises the number
+// of pairs, then minimises the total amount difference and finally the total
+// date distance. It returns the bank index assigned to each system index.
+func assignOptimal(systemTransactions []SystemTransaction, bankTransactions []BankTransaction, opts ReconcileOptions) map[int]int {
+	bankMapByDate := bankIndexByDate(bankTransactions)
+
+	// candidate edges, cost is amount difference first then date distance
+	edges := make([]map[int]int64, len(systemTransactions))
+	dayWeight := int64(opts.Window.MaxDays + 1)
+	for i, sys := range systemTransactions {
+		edges[i] = make(map[int]int64)
+		sysAmount := getSignedAmount(sys)
+
+		for distance, date := range opts.Window.dates(dayOf(sys.TransactionTime)) {
+			for _, idx := range bankMapByDate[date.Format(BankTimeFormat)] {
+				bankTrx := bankTransactions[idx]
+				if bankTrx.Currency != sys.Currency || !opts.toleranceFor(bankTrx.BankName).AllowsIn(sys.Currency, sysAmount, bankTrx.Amount) {
+					continue
+				}
+				edges[i][idx] = addCost(mulCost(amountDistance(sysAmount, bankTrx.Amount), dayWeight), int64(distance))
+			}
+		}
+	}
+
+	assigned := make(map[int]int)
+	for _, component := range connectedComponents(edges, len(bankTransactions)) {
+		sysIdx, bankIdx := component.system, component.bank
+
+		// Costs are capped so that the forbidden cost, their sum, and the
+		// potentials of the solver all stay far from overflowing. Only amount
+		// differences in the order of 10^15 minor units are affected, and
+		// they compare equal.
+		var edgeCount int64
+		for _, i := range sysIdx {
+			edgeCount += int64(len(edges[i]))
+		}
+		edgeCap := math.MaxInt64 / 4 / int64(len(sysIdx)+len(bankIdx)+1) / (edgeCount + 1)
+
+		var feasible int64
+		for _, i := range sysIdx {
+			for _, cost := range edges[i] {
+				feasible += min(cost, edgeCap)
+			}
+		}
+		// any real pair is cheaper than leaving one out
+		forbidden := feasible + 1
+
+		cost := make([][]int64, len(sysIdx))
+		for r, i := range sysIdx {
+			cost[r] = make([]int64, len(bankIdx))
+			for c, idx := range bankIdx {
+				if v, ok := edges[i][idx]; ok {
+					cost[r][c] = min(v, edgeCap)
+				} else {
+					cost[r][c] = forbidden
+				}
+			}
+		}
+
+		for r, c := range solveAssignment(cost) {
+			if c < 0 {
+				continue
+			}
+			if _, ok := edges[sysIdx[r]][bankIdx[c]]; ok {
+				assigned[sysIdx[r]] = bankIdx[c]
+			}
+		}
+	}
+
+	return assigned
+}
+
+// amountDistance returns |a - b|, saturating at math.MaxInt64.
+func amountDistance(a, b Money) int64 {
+	d := int64(a) - int64(b)
+	if (a < 0) != (b < 0) && (d < 0) != (a < 0) {
+		return math.MaxInt64
+	}
+	if d < 0 {
+		if d == math.MinInt64 {
+			return math.MaxInt64
+		}
+		d = -d
+	}
+	return d
+}
+
+// addCost and mulCost combine non-negative costs, saturating at
+// math.MaxInt64.
+func addCost(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func mulCost(a, b int64) int64 {
+	if b != 0 && a > math.MaxInt64/b {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+type component struct {
+	system []int
+	bank   []int
+}
+
+// connectedComponents splits the candidate graph into independent components
+// so each assignment problem stays small. Components and their members are
+// returned in index order.
+func connectedComponents(edges []map[int]int64, bankCount int) []component {
+	parent := make([]int, len(edges)+bankCount)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+
+	for i, e := range edges {
+		for idx := range e {
+			a, b := find(i), find(len(edges)+idx)
+			if a != b {
+				if a < b {
+					parent[b] = a
+				} else {
+					parent[a] = b
+				}
+			}
+		}
+	}
+
+	byRoot := make(map[int]*component)
+	var roots []int
+	for i, e := range edges {
+		if len(e) == 0 {
+			continue
+		}
+		root := find(i)
+		if byRoot[root] == nil {
+			byRoot[root] = &component{}
+			roots = append(roots, root)
+		}
+		byRoot[root].system = append(byRoot[root].system, i)
+	}
+	for idx := 0; idx < bankCount; idx++ {
+		if c, ok := byRoot[find(len(edges)+idx)]; ok {
+			c.bank = append(c.bank, idx)
+		}
+	}
+
+	sort.Ints(roots)
+	components := make([]component, 0, len(roots))
+	for _, root := range roots {
+		components = append(components, *byRoot[root])
+	}
+	return components
+}
+
+// solveAssignment solves the rectangular assignment problem with the
+// Hungarian algorithm and returns the column assigned to each row, or -1 for
+// rows left out when there are more rows than columns.
+func solveAssignment(cost [][]int64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+
+	if rows > cols {
+		transposed := make([][]int64, cols)
+		for c := range transposed {
+			transposed[c] = make([]int64, rows)
+			for r := range cost {
+				transposed[c][r] = cost[r][c]
+			}
+		}
+
+		result := make([]int, rows)
+		for r := range result {
+			result[r] = -1
+		}
+		for c, r := range solveAssignment(transposed) {
+			result[r] = c
+		}
+		return result
+	}
+
+	// potentials and matching are 1-indexed, column 0 is a sentinel
+	u := make([]int64, rows+1)
+	v := make([]int64, cols+1)
+	match := make([]int, cols+1)
+	way := make([]int, cols+1)
+
+	for r := 1; r <= rows; r++ {
+		match[0] = r
+		col := 0
+		minv := make([]int64, cols+1)
+		used := make([]bool, cols+1)
+		for c := range minv {
+			minv[c] = math.MaxInt64
+		}
+
+		for match[col] != 0 {
+			used[col] = true
+			row := match[col]
+			delta := int64(math.MaxInt64)
+			next := 0
+
+			for c := 1; c <= cols; c++ {
+				if used[c] {
+					continue
+				}
+				cur := cost[row-1][c-1] - u[row] - v[c]
+				if cur < minv[c] {
+					minv[c] = cur
+					way[c] = col
+				}
+				if minv[c] < delta {
+					delta = minv[c]
+					next = c
+				}
+			}
+
+			for c := 0; c <= cols; c++ {
+				if used[c] {
+					u[match[c]] += delta
+					v[c] -= delta
+				} else {
+					minv[c] -= delta
+				}
+			}
+			col = next
+		}
+
+		for col != 0 {
+			prev := way[col]
+			match[col] = match[prev]
+			col = prev
+		}
+	}
+
+	result := make([]int, rows)
+	for c := 1; c <= cols; c++ {
+		if match[c] != 0 {
+			result[match[c]-1] = c - 1
+		}
+	}
+	return result
+}
+
+// sortedTransactions returns copies of both inputs in a canonical order so the
+// reconciliation outcome does not depend on the order of the source rows.
+func sortedTransactions(systemTransactions []SystemTransaction, bankTransactions []BankTransaction) ([]SystemTransaction, []BankTransaction) {
+	sysSorted := append([]SystemTransaction(nil), systemTransactions...)
+	sort.SliceStable(sysSorted, func(i, j int) bool {
+		a, b := sysSorted[i], sysSorted[j]
+		if !a.TransactionTime.Equal(b.TransactionTime) {
+			return a.TransactionTime.Before(b.TransactionTime)
+		}
+		if a.TransactionID != b.TransactionID {
+			return a.TransactionID < b.TransactionID
+		}
+		return getSignedAmount(a) < getSignedAmount(b)
+	})
+
+	bankSorted := append([]BankTransaction(nil), bankTransactions...)
+	sort.SliceStable(bankSorted, func(i, j int) bool {
+		a, b := bankSorted[i], bankSorted[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.BankName != b.BankName {
+			return a.BankName < b.BankName
+		}
+		if a.UniqueID != b.UniqueID {
+			return a.UniqueID < b.UniqueID
+		}
+		return a.Amount < b.Amount
+	})
+
+	return sysSorted, bankSorted
+}
@@ -0,0 +1,376 @@
+package reconciliation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bai2Columns names the parts of a detail record a ReferenceRule may read:
+// the customer reference and the text.
+var bai2Columns = []string{"reference", "description"}
+
+// bai2Record is one logical record with its continuation records joined.
+type bai2Record struct {
+	code   string
+	fields []string
+	line   int
+}
+
+// LoadBAI2Statement loads the transaction details of a BAI2 file, in file
+// order, with one statement per account. Details reported as of a day outside
+// start and end are skipped; other details that cannot be parsed are returned
+// as parse errors attributed to file. Amounts are in the account currency,
+// else the group currency, else the profile currency.
+func LoadBAI2Statement(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule) ([]BankTransaction, []Statement, []ParseError, error) {
+	var trxs []BankTransaction
+	statements, parseErrors, err := loadBAI2(r, file, profile, bankName, start, end, rule, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return trxs, statements, parseErrors, nil
+}
+
+// loadBAI2 passes every transaction detail (16) record of a BAI2 file to
+// emit, in file order, and returns the statements read and the records it
+// rejected.
+func loadBAI2(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule, emit func(BankTransaction) error) ([]Statement, []ParseError, error) {
+	refExtractor, err := newReferenceExtractor(rule, bai2Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := locationOrUTC(profile.Location)
+
+	var (
+		statements    []Statement
+		parseErrors   []ParseError
+		current       *Statement
+		asOf          time.Time
+		groupCurrency string
+		currency      string
+	)
+
+	reject := func(rec bai2Record, reason string) {
+		parseErrors = append(parseErrors, ParseError{File: file, Line: rec.line, Column: rec.code, Value: strings.Join(rec.fields, ","), Reason: reason})
+	}
+
+	err = readBAI2Records(r, func(rec bai2Record) error {
+		switch rec.code {
+		case "02":
+			// receiver, originator, status, as-of date, as-of time, currency
+			asOf, groupCurrency = time.Time{}, ""
+			if len(rec.fields) < 4 {
+				reject(rec, "invalid group header (expected as-of date)")
+				return nil
+			}
+			date, err := time.ParseInLocation("060102", rec.fields[3], loc)
+			if err != nil {
+				reject(rec, "invalid as-of date (expected YYMMDD)")
+				return nil
+			}
+			asOf = date
+			if len(rec.fields) > 5 {
+				groupCurrency = normalizeCurrency(rec.fields[5])
+			}
+		case "03":
+			statements = append(statements, Statement{})
+			current = &statements[len(statements)-1]
+			if len(rec.fields) < 1 || strings.TrimSpace(rec.fields[0]) == "" {
+				reject(rec, "invalid account identifier (expected account number)")
+				return nil
+			}
+			current.Account = strings.TrimSpace(rec.fields[0])
+			currency = groupCurrency
+			if len(rec.fields) > 1 && strings.TrimSpace(rec.fields[1]) != "" {
+				currency = normalizeCurrency(rec.fields[1])
+			}
+			if currency == "" {
+				currency = normalizeCurrency(profile.Currency)
+			}
+			if len(rec.fields) > 2 {
+				if err := bai2Summary(rec.fields[2:], current, asOf, currency); err != nil {
+					reject(rec, err.Error())
+				}
+			}
+		case "16":
+			if current == nil || asOf.IsZero() {
+				reject(rec, "transaction detail outside an account")
+				return nil
+			}
+			// statement dates are calendar days, whatever the zone of the period
+			if day := dayOf(asOf); day.Before(dayOf(start)) || day.After(dayOf(end)) {
+				return nil
+			}
+			trx, ok, err := parseBAI2Detail(rec.fields, currency)
+			if err != nil {
+				reject(rec, err.Error())
+				return nil
+			}
+			if !ok {
+				return nil
+			}
+
+			trx.BankName = bankName
+			trx.Date = asOf
+			if trx.UniqueID == "" {
+				trx.UniqueID = fmt.Sprintf("%s:%d", file, rec.line)
+			}
+			if refExtractor != nil {
+				_, trx.Reference = refExtractor.extract([]string{trx.Reference, trx.Description})
+			}
+			return emit(trx)
+		case "49":
+			current = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("no BAI2 account found")
+	}
+
+	return statements, parseErrors, nil
+}
+
+// parseBAI2Detail reads the fields of a 16 record: type code, amount, funds
+// type, bank reference, customer reference and text. Type codes 100 to 399 are
+// credits and 400 to 699 debits; it reports false for non-monetary (890)
+// details. The unique ID is the bank reference, falling back to the customer
+// reference, which is also the reference.
+func parseBAI2Detail(fields []string, currency string) (BankTransaction, bool, error) {
+	if len(fields) < 3 {
+		return BankTransaction{}, false, fmt.Errorf("invalid transaction detail (expected type code, amount and funds type)")
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	var negative bool
+	switch {
+	case err != nil:
+		return BankTransaction{}, false, fmt.Errorf("invalid type code (expected 3 digits)")
+	case code == 890:
+		return BankTransaction{}, false, nil
+	case code >= 100 && code <= 399:
+	case code >= 400 && code <= 699:
+		negative = true
+	default:
+		return BankTransaction{}, false, fmt.Errorf("invalid type code (expected credit 100-399 or debit 400-699)")
+	}
+
+	if currency != "" && !IsCurrencyCode(currency) {
+		return BankTransaction{}, false, fmt.Errorf("invalid currency (expected ISO 4217 code)")
+	}
+	amount, err := parseBAI2Amount(fields[1])
+	if err != nil || amount < 0 {
+		return BankTransaction{}, false, fmt.Errorf("invalid amount")
+	}
+	if negative {
+		amount = -amount
+	}
+
+	i, err := skipBAI2Funds(fields, 2)
+	if err != nil {
+		return BankTransaction{}, false, err
+	}
+	var bankRef, customerRef, text string
+	if i < len(fields) {
+		bankRef = strings.TrimSpace(fields[i])
+	}
+	if i+1 < len(fields) {
+		customerRef = strings.TrimSpace(fields[i+1])
+	}
+	if i+2 < len(fields) {
+		// the text is the rest of the record, commas included
+		text = strings.TrimSpace(strings.Join(fields[i+2:], ","))
+	}
+
+	uniqueID := bankRef
+	if uniqueID == "" {
+		uniqueID = customerRef
+	}
+	return BankTransaction{UniqueID: uniqueID, Amount: amount, Currency: currency, Reference: customerRef, Description: text}, true, nil
+}
+
+// bai2Summary reads the type code, amount, item count and funds type groups
+// of a 03 record into the opening (010) and closing (015) ledger balances of
+// statement.
+func bai2Summary(fields []string, statement *Statement, asOf time.Time, currency string) error {
+	for i := 0; i < len(fields); {
+		code := strings.TrimSpace(fields[i])
+		if code == "" {
+			break
+		}
+		if i+1 >= len(fields) {
+			return fmt.Errorf("invalid account summary (expected type code and amount)")
+		}
+		raw := strings.TrimSpace(fields[i+1])
+
+		next, err := skipBAI2Funds(fields, i+3)
+		if err != nil {
+			return err
+		}
+		i = next
+
+		if raw == "" || code != "010" && code != "015" {
+			continue
+		}
+		amount, err := parseBAI2Amount(raw)
+		if err != nil {
+			return fmt.Errorf("invalid amount")
+		}
+		balance := &StatementBalance{Date: asOf, Currency: currency, Amount: amount}
+		if code == "010" {
+			statement.OpeningBalance = balance
+		} else {
+			statement.ClosingBalance = balance
+		}
+	}
+	return nil
+}
+
+// skipBAI2Funds returns the index of the field after the funds type at i and
+// its availability or value date fields.
+func skipBAI2Funds(fields []string, i int) (int, error) {
+	if i >= len(fields) {
+		return i, nil
+	}
+	next := i + 1
+	switch strings.ToUpper(strings.TrimSpace(fields[i])) {
+	case "", "0", "1", "2", "Z":
+	case "V":
+		// value date and time
+		next += 2
+	case "S":
+		// immediate, one day and two or more days amounts
+		next += 3
+	case "D":
+		if next >= len(fields) {
+			return 0, fmt.Errorf("invalid funds type (expected distribution count)")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(fields[next]))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid funds type (expected distribution count)")
+		}
+		next += 1 + 2*n
+	default:
+		return 0, fmt.Errorf("invalid funds type (expected 0, 1, 2, V, S, D or Z)")
+	}
+	if next > len(fields) {
+		return 0, fmt.Errorf("invalid funds type (expected availability fields)")
+	}
+	return next, nil
+}
+
+// parseBAI2Amount reads an amount in minor units with an optional sign, the
+// decimals being implied by the currency.
+func parseBAI2Amount(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	digits := strings.TrimLeft(value, "+-")
+	if digits == "" || !isDigits(digits) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return Money(n), nil
+}
+
+// readBAI2Records splits a BAI2 file into records, joining the 88
+// continuation records to the record they continue. The text of a
+// transaction detail continues after a space once the record reaches it,
+// other fields after a comma. The text runs to the end of the line, so the
+// "/" ending a record is only dropped from records without one.
+func readBAI2Records(r io.Reader, record func(bai2Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		current *bai2Record
+		lineNo  int
+	)
+	emit := func() error {
+		if current == nil {
+			return nil
+		}
+		rec := *current
+		current = nil
+		return record(rec)
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line == "/" {
+			continue
+		}
+
+		code, rest, _ := strings.Cut(line, ",")
+		if code == "88" {
+			if current == nil {
+				continue
+			}
+			if _, ok := current.textStart(); ok {
+				last := len(current.fields) - 1
+				current.fields[last] = strings.TrimSpace(current.fields[last] + " " + bai2Text(rest))
+			} else {
+				current.fields = append(current.fields, strings.Split(rest, ",")...)
+				current.endLine()
+			}
+			continue
+		}
+
+		if err := emit(); err != nil {
+			return err
+		}
+		current = &bai2Record{code: strings.TrimSuffix(code, "/"), fields: strings.Split(rest, ","), line: lineNo}
+		current.endLine()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return emit()
+}
+
+// textStart returns the field where the text of a 16 record starts, after
+// the funds type, bank reference and customer reference, and whether the
+// fields reach it.
+func (rec *bai2Record) textStart() (int, bool) {
+	if rec.code != "16" {
+		return 0, false
+	}
+	i, err := skipBAI2Funds(rec.fields, 2)
+	return i + 2, err == nil && len(rec.fields) > i+2
+}
+
+// endLine drops the "/" ending the line just read into rec, unless the line
+// reached the text, of which the "/" is part when it is not the whole text.
+func (rec *bai2Record) endLine() {
+	if i, ok := rec.textStart(); ok {
+		if bai2Text(strings.Join(rec.fields[i:], ",")) == "" {
+			rec.fields = append(rec.fields[:i], "")
+		}
+		return
+	}
+	last := len(rec.fields) - 1
+	rec.fields[last] = strings.TrimSuffix(rec.fields[last], "/")
+}
+
+// bai2Text returns text read up to the end of a line, empty when it is only
+// the "/" ending the record.
+func bai2Text(text string) string {
+	if text = strings.TrimSpace(text); text == "/" {
+		return ""
+	}
+	return text
+}
@@ -0,0 +1,324 @@
+package reconciliation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// camtColumns names the parts of an entry a ReferenceRule may read: the
+// end-to-end ID and the remittance information.
+var camtColumns = []string{"reference", "description"}
+
+// camtDate is a date or date time element such as BookgDt or ValDt.
+type camtDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Cd   string `xml:"Cd"`
+}
+
+type camtTxDetails struct {
+	EndToEndID  string   `xml:"Refs>EndToEndId"`
+	AcctSvcrRef string   `xml:"Refs>AcctSvcrRef"`
+	Ustrd       []string `xml:"RmtInf>Ustrd"`
+	AddtlTxInf  string   `xml:"AddtlTxInf"`
+}
+
+type camtEntry struct {
+	NtryRef      string          `xml:"NtryRef"`
+	Amt          camtAmount      `xml:"Amt"`
+	CdtDbtInd    string          `xml:"CdtDbtInd"`
+	RvslInd      bool            `xml:"RvslInd"`
+	Sts          camtStatus      `xml:"Sts"`
+	BookgDt      camtDate        `xml:"BookgDt"`
+	ValDt        camtDate        `xml:"ValDt"`
+	AcctSvcrRef  string          `xml:"AcctSvcrRef"`
+	TxDtls       []camtTxDetails `xml:"NtryDtls>TxDtls"`
+	AddtlNtryInf string          `xml:"AddtlNtryInf"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Dt        camtDate   `xml:"Dt"`
+}
+
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+	Ccy   string `xml:"Ccy"`
+}
+
+// LoadCAMTStatement loads the entries of an ISO 20022 camt.053 statement or
+// camt.054 debit/credit notification, in file order, with the statements or
+// notifications it holds. Entries booked outside start and end, and entries
+// not booked yet, are skipped; other entries that cannot be parsed are
+// returned as parse errors attributed to file.
+func LoadCAMTStatement(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule) ([]BankTransaction, []Statement, []ParseError, error) {
+	var trxs []BankTransaction
+	statements, parseErrors, err := loadCAMT(r, file, profile, bankName, start, end, rule, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return trxs, statements, parseErrors, nil
+}
+
+// loadCAMT passes every booked entry of a camt.053 or camt.054 file to emit,
+// in file order, decoding one entry at a time.
+func loadCAMT(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule, emit func(BankTransaction) error) ([]Statement, []ParseError, error) {
+	refExtractor, err := newReferenceExtractor(rule, camtColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := locationOrUTC(profile.Location)
+	amountFormat := AmountFormat{Rounding: profile.Rounding}
+
+	var (
+		statements  []Statement
+		parseErrors []ParseError
+		current     *Statement
+		currency    string
+		path        []string
+	)
+
+	reject := func(line int, column, value, reason string) {
+		parseErrors = append(parseErrors, ParseError{File: file, Line: line, Column: column, Value: value, Reason: reason})
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid XML: %v", err)
+		}
+		line, _ := dec.InputPos()
+
+		switch el := tok.(type) {
+		case xml.EndElement:
+			path = path[:len(path)-1]
+			continue
+		case xml.StartElement:
+			name := el.Name.Local
+			parent := ""
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+
+			switch {
+			case name == "Stmt" || name == "Ntfctn":
+				statements = append(statements, Statement{})
+				current = &statements[len(statements)-1]
+				currency = ""
+			case current == nil || parent != "Stmt" && parent != "Ntfctn":
+			case name == "Id":
+				if err := dec.DecodeElement(&current.Reference, &el); err != nil {
+					return nil, nil, fmt.Errorf("invalid XML: %v", err)
+				}
+				continue
+			case name == "ElctrncSeqNb" || name == "LglSeqNb" && current.Number == "":
+				if err := dec.DecodeElement(&current.Number, &el); err != nil {
+					return nil, nil, fmt.Errorf("invalid XML: %v", err)
+				}
+				continue
+			case name == "Acct":
+				var acct camtAccount
+				if err := dec.DecodeElement(&acct, &el); err != nil {
+					return nil, nil, fmt.Errorf("invalid XML: %v", err)
+				}
+				current.Account = strings.TrimSpace(acct.IBAN)
+				if current.Account == "" {
+					current.Account = strings.TrimSpace(acct.Other)
+				}
+				currency = normalizeCurrency(acct.Ccy)
+				continue
+			case name == "Bal":
+				var bal camtBalance
+				if err := dec.DecodeElement(&bal, &el); err != nil {
+					return nil, nil, fmt.Errorf("invalid XML: %v", err)
+				}
+				balance, err := bal.balance(loc, amountFormat)
+				if err != nil {
+					reject(line, "Bal", bal.Amt.Value, err.Error())
+					continue
+				}
+				switch bal.Code {
+				case "OPBD", "PRCD":
+					if current.OpeningBalance == nil || bal.Code == "OPBD" {
+						current.OpeningBalance = balance
+					}
+				case "CLBD":
+					current.ClosingBalance = balance
+				}
+				continue
+			case name == "Ntry":
+				var entry camtEntry
+				if err := dec.DecodeElement(&entry, &el); err != nil {
+					return nil, nil, fmt.Errorf("invalid XML: %v", err)
+				}
+				trx, ok, err := entry.transaction(currency, profile, amountFormat)
+				if err != nil {
+					reject(line, "Ntry", entry.Amt.Value, err.Error())
+					continue
+				}
+				// statement dates are calendar days, whatever the zone of the period
+				if !ok || dayOf(trx.Date).Before(dayOf(start)) || dayOf(trx.Date).After(dayOf(end)) {
+					continue
+				}
+
+				trx.BankName = bankName
+				if trx.UniqueID == "" {
+					trx.UniqueID = fmt.Sprintf("%s:%d", file, line)
+				}
+				if refExtractor != nil {
+					_, trx.Reference = refExtractor.extract([]string{trx.Reference, trx.Description})
+				}
+				if err := emit(trx); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			path = append(path, name)
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("no camt.053 statement or camt.054 notification found")
+	}
+	return statements, parseErrors, nil
+}
+
+// transaction maps a booked entry to a bank transaction. It reports false for
+// entries not booked yet. The unique ID is the account servicer reference of
+// the entry or its first transaction, then the entry reference; the reference
+// is the first end-to-end ID provided.
+func (e camtEntry) transaction(currency string, profile BankProfile, format AmountFormat) (BankTransaction, bool, error) {
+	if status := strings.TrimSpace(e.Sts.Text + e.Sts.Cd); status != "" && status != "BOOK" {
+		return BankTransaction{}, false, nil
+	}
+
+	if c := normalizeCurrency(e.Amt.Currency); c != "" {
+		currency = c
+	}
+	if currency == "" {
+		currency = normalizeCurrency(profile.Currency)
+	}
+	if currency != "" && !IsCurrencyCode(currency) {
+		return BankTransaction{}, false, fmt.Errorf("invalid currency (expected ISO 4217 code)")
+	}
+
+	amount, err := ParseCurrencyMoney(e.Amt.Value, currency, format)
+	if err != nil {
+		return BankTransaction{}, false, fmt.Errorf("invalid amount")
+	}
+	debit := e.CdtDbtInd == "DBIT"
+	if !debit && e.CdtDbtInd != "CRDT" {
+		return BankTransaction{}, false, fmt.Errorf("invalid CdtDbtInd (expected CRDT or DBIT)")
+	}
+	// a reversed credit is a debit and a reversed debit a credit
+	if debit != e.RvslInd {
+		amount = -amount
+	}
+
+	date := e.BookgDt
+	if date.isZero() {
+		date = e.ValDt
+	}
+	if date.isZero() {
+		return BankTransaction{}, false, fmt.Errorf("missing BookgDt and ValDt")
+	}
+	day, err := date.day(locationOrUTC(profile.Location))
+	if err != nil {
+		return BankTransaction{}, false, err
+	}
+
+	trx := BankTransaction{
+		UniqueID: strings.TrimSpace(e.AcctSvcrRef),
+		Amount:   amount,
+		Currency: currency,
+		Date:     day,
+	}
+
+	var info []string
+	for _, tx := range e.TxDtls {
+		if trx.UniqueID == "" {
+			trx.UniqueID = strings.TrimSpace(tx.AcctSvcrRef)
+		}
+		if id := strings.TrimSpace(tx.EndToEndID); trx.Reference == "" && id != "" && id != "NOTPROVIDED" {
+			trx.Reference = id
+		}
+		for _, u := range tx.Ustrd {
+			if u = strings.TrimSpace(u); u != "" {
+				info = append(info, u)
+			}
+		}
+		if len(tx.Ustrd) == 0 && strings.TrimSpace(tx.AddtlTxInf) != "" {
+			info = append(info, strings.TrimSpace(tx.AddtlTxInf))
+		}
+	}
+	if trx.UniqueID == "" {
+		trx.UniqueID = strings.TrimSpace(e.NtryRef)
+	}
+	trx.Description = strings.Join(info, " ")
+	if trx.Description == "" {
+		trx.Description = strings.TrimSpace(e.AddtlNtryInf)
+	}
+
+	return trx, true, nil
+}
+
+func (b camtBalance) balance(loc *time.Location, format AmountFormat) (*StatementBalance, error) {
+	currency := normalizeCurrency(b.Amt.Currency)
+	amount, err := ParseCurrencyMoney(b.Amt.Value, currency, format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount")
+	}
+	if b.CdtDbtInd == "DBIT" {
+		amount = -amount
+	}
+	day, err := b.Dt.day(loc)
+	if err != nil {
+		return nil, err
+	}
+	return &StatementBalance{Date: day, Currency: currency, Amount: amount}, nil
+}
+
+func (d camtDate) isZero() bool {
+	return strings.TrimSpace(d.Dt) == "" && strings.TrimSpace(d.DtTm) == ""
+}
+
+// day returns the calendar day of the element in loc. A date time without
+// offset is read in loc.
+func (d camtDate) day(loc *time.Location) (time.Time, error) {
+	if v := strings.TrimSpace(d.Dt); v != "" {
+		t, err := time.ParseInLocation(BankTimeFormat, v, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date (expected YYYY-MM-DD)")
+		}
+		return t, nil
+	}
+
+	t, err := parseTime(d.DtTm, []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date time (expected ISO 8601)")
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+}
@@ -0,0 +1,46 @@
+package reconciliation
+
+import (
+	"math"
+	"time"
+)
+
+// confidenceDayPenalty is the confidence lost for each day between the system
+// transaction and the bank posting date.
+const confidenceDayPenalty = 0.1
+
+// matchConfidence scores a match between 0 and 1. An exact amount posted on
+// the same day scores 1; the score drops with the amount difference relative
+// to the larger amount and with the number of days in between.
+func matchConfidence(sysAmount, bankAmount Money, days int) float64 {
+	amountScore := 1.0
+	if diff := absMoney(sysAmount - bankAmount); diff != 0 {
+		base := max(absMoney(sysAmount), absMoney(bankAmount))
+		amountScore = math.Max(0, 1-float64(diff)/float64(base))
+	}
+
+	dateScore := math.Max(0, 1-float64(days)*confidenceDayPenalty)
+
+	return math.Round(amountScore*dateScore*10000) / 10000
+}
+
+// groupConfidence scores a split match. Amounts add up exactly, so only the
+// largest date distance within the group counts.
+func groupConfidence(group GroupMatch) float64 {
+	days := 0
+	for _, sys := range group.System {
+		for _, b := range group.Bank {
+			days = max(days, daysBetween(dayOf(sys.TransactionTime), b.Date))
+		}
+	}
+	return matchConfidence(0, 0, days)
+}
+
+// daysBetween returns the number of calendar days between a and b.
+func daysBetween(a, b time.Time) int {
+	days := int(math.Round(dayOf(b).Sub(dayOf(a)).Hours() / 24))
+	if days < 0 {
+		return -days
+	}
+	return days
+}
@@ -1,7 +1,9 @@
 package reconciliation
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -12,10 +14,14 @@ const (
 	Credit TransactionType = "CREDIT"
 )
 
+// Money counts minor units of a currency, see CurrencyDigits. Without a
+// currency it counts hundredths.
 type Money int64
 
+// ToMoney converts a float amount to Money, rounding to the nearest minor
+// unit. Use ParseMoney for amounts read as text.
 func ToMoney(amount float64) Money {
-	return Money(amount * 100)
+	return Money(math.Round(amount * 100))
 }
 
 func (m Money) ToFloat() float64 {
@@ -25,26 +31,417 @@ func (m Money) ToFloat() float64 {
 type SystemTransaction struct {
 	TransactionID   string
 	Amount          Money
+	Currency        string
 	Type            TransactionType
 	TransactionTime time.Time
 }
 
+// MarshalJSON renders Amount with the decimals of the transaction currency.
+func (t SystemTransaction) MarshalJSON() ([]byte, error) {
+	type plain SystemTransaction
+	return json.Marshal(struct {
+		plain
+		Amount json.RawMessage
+	}{plain(t), json.RawMessage(t.Amount.Format(t.Currency))})
+}
+
 type BankTransaction struct {
-	BankName string
-	UniqueID string
-	Amount   Money
-	Date     time.Time
+	BankName    string
+	UniqueID    string
+	Amount      Money
+	Currency    string
+	Date        time.Time
+	Description string
+	Reference   string
+}
+
+// MarshalJSON renders Amount with the decimals of the transaction currency.
+func (t BankTransaction) MarshalJSON() ([]byte, error) {
+	type plain BankTransaction
+	return json.Marshal(struct {
+		plain
+		Amount json.RawMessage
+	}{plain(t), json.RawMessage(t.Amount.Format(t.Currency))})
+}
+
+// Tolerance bounds how far a bank amount may drift from the system amount and
+// still be accepted as a near-match. When both bounds are set the looser one
+// wins. A zero Tolerance places no bound at all. Amount is in hundredths of
+// the major unit whatever the currency.
+type Tolerance struct {
+	Amount  Money
+	Percent float64
+}
+
+func (t Tolerance) IsZero() bool {
+	return t.Amount == 0 && t.Percent == 0
+}
+
+// Allows reports whether the difference between sysAmount and bankAmount is
+// within the tolerance.
+func (t Tolerance) Allows(sysAmount, bankAmount Money) bool {
+	return t.AllowsIn("", sysAmount, bankAmount)
+}
+
+// AllowsIn reports whether the difference between sysAmount and bankAmount,
+// both in minor units of currency, is within the tolerance.
+func (t Tolerance) AllowsIn(currency string, sysAmount, bankAmount Money) bool {
+	if t.IsZero() {
+		return true
+	}
+
+	diff := absMoney(sysAmount - bankAmount)
+	if t.Amount > 0 && diff <= t.Amount.rescale(moneyDigits, CurrencyDigits(currency)) {
+		return true
+	}
+	if t.Percent > 0 && float64(diff) <= float64(absMoney(sysAmount))*t.Percent/100 {
+		return true
+	}
+	return false
+}
+
+// bounds reports whether bankAmount is within the tolerance of sysAmount,
+// reading a zero tolerance as exact where AllowsIn reads it as unbounded.
+func (t Tolerance) bounds(currency string, sysAmount, bankAmount Money) bool {
+	if t.IsZero() {
+		return sysAmount == bankAmount
+	}
+	return t.AllowsIn(currency, sysAmount, bankAmount)
+}
+
+// MaxSettlementDays bounds SettlementWindow.MaxDays.
+const MaxSettlementDays = 31
+
+// SettlementWindow lets a bank line be posted up to MaxDays after the system
+// transaction date. With SkipWeekends, Saturdays and Sundays are not counted
+// as settlement days, and Holidays are never counted.
+type SettlementWindow struct {
+	MaxDays      int
+	SkipWeekends bool
+	Holidays     []time.Time
+}
+
+func (w SettlementWindow) validate() error {
+	if w.MaxDays < 0 || w.MaxDays > MaxSettlementDays {
+		return fmt.Errorf("invalid settlement_days (expected integer between 0 and %d)", MaxSettlementDays)
+	}
+	return nil
+}
+
+func (w SettlementWindow) isSettlementDay(day time.Time) bool {
+	if w.SkipWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return false
+	}
+	for _, h := range w.Holidays {
+		if dayOf(h).Equal(day) {
+			return false
+		}
+	}
+	return true
+}
+
+// dates returns the bank posting dates a transaction on day may be matched
+// against, closest first. The day itself is always included.
+func (w SettlementWindow) dates(day time.Time) []time.Time {
+	dates := []time.Time{day}
+	for next := day; len(dates) <= w.MaxDays; {
+		next = next.AddDate(0, 0, 1)
+		if w.isSettlementDay(next) {
+			dates = append(dates, next)
+		}
+	}
+	return dates
+}
+
+// lastDate returns the latest bank posting date for a transaction on day.
+func (w SettlementWindow) lastDate(day time.Time) time.Time {
+	for n := 0; n < w.MaxDays; {
+		day = day.AddDate(0, 0, 1)
+		if w.isSettlementDay(day) {
+			n++
+		}
+	}
+	return day
+}
+
+// MatchMode selects how the discrepancy stage pairs the remaining transactions.
+type MatchMode string
+
+const (
+	// MatchModeGreedy pairs each system transaction with the first acceptable
+	// bank line in row order.
+	MatchModeGreedy MatchMode = "greedy"
+	// MatchModeOptimal pairs transactions by minimum-cost bipartite assignment
+	// over amount difference and date distance.
+	MatchModeOptimal MatchMode = "optimal"
+)
+
+// MaxSplitGroupSize bounds ReconcileOptions.MaxGroupSize, as the split search
+// grows exponentially with the group size.
+const MaxSplitGroupSize = 5
+
+// ReconcileOptions holds the per request matching parameters.
+type ReconcileOptions struct {
+	Tolerance      Tolerance
+	BankTolerances map[string]Tolerance
+	Window         SettlementWindow
+	// MaxGroupSize enables split matching of up to this many transactions
+	// per group, at most MaxSplitGroupSize. Zero or one disables it.
+	MaxGroupSize   int
+	Mode           MatchMode
+	Reference      ReferenceRule
+	BankReferences map[string]ReferenceRule
+	// MinConfidence moves matches scoring below it back to the unmatched
+	// lists.
+	MinConfidence float64
+	// SystemProfile names the layout of the system export. Empty means the
+	// default profile.
+	SystemProfile string
+	// BankProfiles names the layout of each uploaded bank statement, in
+	// upload order. A single profile applies to every file. Missing, empty
+	// or AutoProfileName entries detect the profile from the upload.
+	BankProfiles []string
+	// BankFormats names the file format of each uploaded bank statement,
+	// like BankProfiles. Missing or empty entries detect the format.
+	BankFormats []BankFormat
+	// SheetName and HeaderRow select the worksheet of XLSX uploads and the
+	// row, counted from one, holding its header. Empty and zero mean the
+	// first sheet and its first row with a value.
+	SheetName string
+	HeaderRow int
+	// Strict fails the whole request when any row or file is rejected.
+	Strict bool
+	// Currency is the currency of transactions whose file does not tell.
+	Currency string
+	// FXRates enables matching across currencies. FXTolerance bounds the
+	// difference between the converted system amount and the bank amount;
+	// zero asks for the exact converted amount.
+	FXRates     *FXRates
+	FXTolerance Tolerance
+	// FeeRules recognise bank fees, either deducted from bank lines or
+	// booked as separate lines.
+	FeeRules []FeeRule
+	// Timezone is the zone system transactions are bucketed into days in,
+	// their own zone when nil.
+	Timezone *time.Location
+	// Workers matches independent day blocks on up to this many goroutines.
+	// Zero uses the service setting, one matches sequentially.
+	Workers int
+}
+
+// toleranceFor returns the tolerance for the given bank, falling back to the
+// request wide tolerance.
+func (o ReconcileOptions) toleranceFor(bankName string) Tolerance {
+	if t, ok := o.BankTolerances[bankName]; ok {
+		return t
+	}
+	return o.Tolerance
 }
 
+// MatchRule names the matching stage that produced a match.
+type MatchRule string
+
+const (
+	RuleReference          MatchRule = "reference"
+	RuleExactKey           MatchRule = "exact_key"
+	RuleSplit              MatchRule = "split"
+	RuleSameDayDiscrepancy MatchRule = "same_day_discrepancy"
+)
+
+// MatchedPair pairs a system transaction with the bank line it matched.
+// FX is set when the pair spans two currencies. Fee is the bank fee deducted
+// from the bank line, in the bank currency.
+type MatchedPair struct {
+	System     SystemTransaction
+	Bank       BankTransaction
+	Rule       MatchRule
+	Confidence float64
+	FX         *FXConversion `json:",omitempty"`
+	Fee        Money         `json:",omitempty"`
+}
+
+// MarshalJSON renders Fee with the decimals of the bank currency.
+func (p MatchedPair) MarshalJSON() ([]byte, error) {
+	type plain MatchedPair
+	var fee json.RawMessage
+	if p.Fee != 0 {
+		fee = json.RawMessage(p.Fee.Format(p.Bank.Currency))
+	}
+	return json.Marshal(struct {
+		plain
+		Fee json.RawMessage `json:",omitempty"`
+	}{plain(p), fee})
+}
+
+// Discrepancy details a matched pair whose amounts differ. Difference is the
+// signed system amount minus the bank amount.
+type Discrepancy struct {
+	SystemID     string
+	BankUniqueID string
+	BankName     string
+	Currency     string
+	SystemAmount Money
+	BankAmount   Money
+	Difference   Money
+}
+
+// MarshalJSON renders the amounts with the decimals of the currency.
+func (d Discrepancy) MarshalJSON() ([]byte, error) {
+	type plain Discrepancy
+	return json.Marshal(struct {
+		plain
+		SystemAmount json.RawMessage
+		BankAmount   json.RawMessage
+		Difference   json.RawMessage
+	}{
+		plain(d),
+		json.RawMessage(d.SystemAmount.Format(d.Currency)),
+		json.RawMessage(d.BankAmount.Format(d.Currency)),
+		json.RawMessage(d.Difference.Format(d.Currency)),
+	})
+}
+
+// CurrencyTotals breaks the result counters down for one currency. Pairs
+// across currencies count in the bank currency, and their FX differences add
+// up in TotalFXDifferences rather than TotalDiscrepancies. TotalFees adds up
+// the fees deducted from matched bank lines and the separate fee lines.
+type CurrencyTotals struct {
+	Currency           string
+	TotalMatched       int
+	TotalGroupMatched  int
+	TotalUnmatched     int
+	TotalDiscrepancies Money
+	TotalFXDifferences Money
+	TotalFees          Money
+}
+
+// MarshalJSON renders the amounts with the decimals of the currency.
+func (c CurrencyTotals) MarshalJSON() ([]byte, error) {
+	type plain CurrencyTotals
+	return json.Marshal(struct {
+		plain
+		TotalDiscrepancies json.RawMessage
+		TotalFXDifferences json.RawMessage
+		TotalFees          json.RawMessage
+	}{
+		plain(c),
+		json.RawMessage(c.TotalDiscrepancies.Format(c.Currency)),
+		json.RawMessage(c.TotalFXDifferences.Format(c.Currency)),
+		json.RawMessage(c.TotalFees.Format(c.Currency)),
+	})
+}
+
+// GroupMatch is a split match where several transactions on one side add up
+// exactly to a single transaction on the other side.
+type GroupMatch struct {
+	System     []SystemTransaction
+	Bank       []BankTransaction
+	Rule       MatchRule
+	Confidence float64
+}
+
+// referenceFor returns the reference rule for the given bank, falling back to
+// the request wide rule.
+func (o ReconcileOptions) referenceFor(bankName string) ReferenceRule {
+	if r, ok := o.BankReferences[bankName]; ok {
+		return r
+	}
+	return o.Reference
+}
+
+// ReconciliationResult is the outcome of a reconciliation. TotalDiscrepancies
+// and TotalFees are only set when the transactions share one currency, since
+// amounts in different currencies do not add up; Currencies always breaks
+// them down per currency.
 type ReconciliationResult struct {
 	TotalProcessed     int
 	TotalMatched       int
+	TotalGroupMatched  int
 	TotalUnmatched     int
 	TotalDiscrepancies Money
+	TotalFees          Money
+	Matched            []MatchedPair
+	Discrepancies      []Discrepancy
+	GroupMatches       []GroupMatch
 	UnmatchedSystem    []SystemTransaction
 	UnmatchedBank      map[string][]BankTransaction
+	Fees               []BankTransaction
+	Tolerance          Tolerance
+	BankTolerances     map[string]Tolerance
+	SettlementWindow   SettlementWindow
+	MaxGroupSize       int
+	MatchMode          MatchMode
+	MinConfidence      float64
+	Files              []FileReport
+	ParseErrors        []ParseError
+	Currencies         []CurrencyTotals
+}
+
+// MarshalJSON renders the totals with the decimals of the currency of the
+// result, or as null when it has several currencies.
+func (r ReconciliationResult) MarshalJSON() ([]byte, error) {
+	type plain ReconciliationResult
+	discrepancies, fees := json.RawMessage("null"), json.RawMessage("null")
+	switch len(r.Currencies) {
+	case 0:
+		discrepancies, fees = json.RawMessage(r.TotalDiscrepancies.Format("")), json.RawMessage(r.TotalFees.Format(""))
+	case 1:
+		currency := r.Currencies[0].Currency
+		discrepancies, fees = json.RawMessage(r.TotalDiscrepancies.Format(currency)), json.RawMessage(r.TotalFees.Format(currency))
+	}
+	return json.Marshal(struct {
+		plain
+		TotalDiscrepancies json.RawMessage
+		TotalFees          json.RawMessage
+	}{plain(r), discrepancies, fees})
+}
+
+// Statement summarises one statement of a bank file and the booked balances
+// it reports, when the format carries them.
+type Statement struct {
+	Reference      string
+	Account        string
+	Number         string
+	OpeningBalance *StatementBalance `json:",omitempty"`
+	ClosingBalance *StatementBalance `json:",omitempty"`
+}
+
+// StatementBalance is a booked balance, negative when in debit.
+type StatementBalance struct {
+	Date     time.Time
+	Currency string
+	Amount   Money
+}
+
+// MarshalJSON renders Amount with the decimals of the balance currency.
+func (b StatementBalance) MarshalJSON() ([]byte, error) {
+	type plain StatementBalance
+	return json.Marshal(struct {
+		plain
+		Amount json.RawMessage
+	}{plain(b), json.RawMessage(b.Amount.Format(b.Currency))})
+}
+
+// FileReport tells how an uploaded bank file was read. Error is set when the
+// file was skipped. Statements lists the statements of formats that carry
+// balances.
+type FileReport struct {
+	FileName     string
+	Format       BankFormat
+	Profile      string
+	BankName     string
+	Transactions int
+	Statements   []Statement `json:",omitempty"`
+	Error        string
 }
 
 func (m Money) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf("%.2f", m.ToFloat())), nil
+	return []byte(m.Format("")), nil
+}
+
+func absMoney(m Money) Money {
+	if m < 0 {
+		return -m
+	}
+	return m
 }
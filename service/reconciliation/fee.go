@@ -0,0 +1,178 @@
+package reconciliation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// RuleFee names matches where the bank line is the system amount less a
+// known bank fee, when the fee rule has no name of its own.
+const RuleFee MatchRule = "fee"
+
+// FeeRule describes a charge a bank takes on transfers. Amount and Percent
+// give a fee deducted from the bank line, so the line is the signed system
+// amount minus Amount plus Percent of the system amount. Pattern recognises
+// fees booked as separate bank lines by their description or unique ID. An
+// empty Bank applies the rule to every bank. Amount is in hundredths of the
+// major unit whatever the currency, like Tolerance.
+type FeeRule struct {
+	Name    string
+	Bank    string
+	Amount  Money
+	Percent float64
+	Pattern string
+}
+
+// appliesTo tells whether the rule covers lines of the named bank.
+func (r FeeRule) appliesTo(bankName string) bool {
+	return r.Bank == "" || strings.EqualFold(r.Bank, bankName)
+}
+
+// deducts tells whether the rule describes a fee deducted from the bank line.
+func (r FeeRule) deducts() bool {
+	return r.Amount > 0 || r.Percent > 0
+}
+
+// fee returns the fee charged on the signed system amount, in minor units of
+// currency, rounding the percentage half away from zero.
+func (r FeeRule) fee(currency string, sysAmount Money) Money {
+	fee := r.Amount.rescale(moneyDigits, CurrencyDigits(currency))
+	if r.Percent > 0 {
+		fee += Money(math.Round(float64(absMoney(sysAmount)) * r.Percent / 100))
+	}
+	return fee
+}
+
+// ValidateFeeRules checks a fee rule set before it is used by the service.
+func ValidateFeeRules(rules []FeeRule) error {
+	names := map[string]bool{}
+	for _, m := range DefaultMatchers() {
+		names[m.Name()] = true
+	}
+
+	for i, rule := range rules {
+		switch {
+		case strings.TrimSpace(rule.Name) == "":
+			return fmt.Errorf("fee rule #%d: name is required", i+1)
+		case names[rule.Name]:
+			return fmt.Errorf("fee rule %q: duplicate or reserved name", rule.Name)
+		case rule.Amount < 0 || rule.Percent < 0:
+			return fmt.Errorf("fee rule %q: fee must not be negative", rule.Name)
+		case rule.Percent > 100:
+			return fmt.Errorf("fee rule %q: fee percent must not exceed 100", rule.Name)
+		case !rule.deducts() && rule.Pattern == "":
+			return fmt.Errorf("fee rule %q: amount, percent or pattern is required", rule.Name)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("fee rule %q: invalid pattern %q: %v", rule.Name, rule.Pattern, err)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// WithFeeRules sets the bank fee rules. Rules are expected to pass
+// ValidateFeeRules.
+func WithFeeRules(rules ...FeeRule) Option {
+	return func(s *reconciliationService) {
+		s.feeRules = append(s.feeRules, rules...)
+	}
+}
+
+// separateFees takes the bank lines recognised as fees by a rule pattern out
+// of the lines to match.
+func separateFees(bank []BankTransaction, rules []FeeRule) (rest, fees []BankTransaction) {
+	type feePattern struct {
+		rule    FeeRule
+		pattern *regexp.Regexp
+	}
+	var patterns []feePattern
+	for _, rule := range rules {
+		if rule.Pattern != "" {
+			patterns = append(patterns, feePattern{rule: rule, pattern: regexp.MustCompile(rule.Pattern)})
+		}
+	}
+	if len(patterns) == 0 {
+		return bank, nil
+	}
+
+	for _, b := range bank {
+		isFee := false
+		for _, p := range patterns {
+			if p.rule.appliesTo(b.BankName) && (p.pattern.MatchString(b.Description) || p.pattern.MatchString(b.UniqueID)) {
+				isFee = true
+				break
+			}
+		}
+		if isFee {
+			fees = append(fees, b)
+		} else {
+			rest = append(rest, b)
+		}
+	}
+	return rest, fees
+}
+
+type feeMatcher struct{}
+
+// FeeMatcher pairs system transactions with a bank line of the same currency
+// posted within the settlement window, closest date first, whose amount is
+// the system amount less the fee of a request FeeRules rule for that bank.
+// Matches report the fee rule name.
+func FeeMatcher() Matcher {
+	return feeMatcher{}
+}
+
+func (feeMatcher) Name() string {
+	return string(RuleFee)
+}
+
+func (feeMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	var rules []FeeRule
+	for _, rule := range opts.FeeRules {
+		if rule.deducts() {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	bankMapByDate := bankIndexByDate(bank)
+	usedBank := make([]bool, len(bank))
+
+	for i, sys := range system {
+		sysAmount := getSignedAmount(sys)
+		found := false
+
+		for _, date := range opts.Window.dates(dayOf(sys.TransactionTime)) {
+			for _, idx := range bankMapByDate[date.Format(BankTimeFormat)] {
+				b := bank[idx]
+				if usedBank[idx] || b.Currency != sys.Currency {
+					continue
+				}
+
+				for _, rule := range rules {
+					fee := rule.fee(sys.Currency, sysAmount)
+					if !rule.appliesTo(b.BankName) || fee == 0 || sysAmount-fee != b.Amount {
+						continue
+					}
+
+					usedBank[idx] = true
+					found = true
+					matches = append(matches, Match{System: []int{i}, Bank: []int{idx}, Rule: MatchRule(rule.Name), Fee: fee})
+					break
+				}
+				if found {
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+	return matches
+}
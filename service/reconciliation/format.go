@@ -0,0 +1,98 @@
+package reconciliation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BankFormat is the file format of a bank statement upload.
+type BankFormat string
+
+const (
+	// BankFormatCSV is a delimited export described by a bank profile.
+	BankFormatCSV BankFormat = "csv"
+	// BankFormatMT940 is a SWIFT MT940 customer statement.
+	BankFormatMT940 BankFormat = "mt940"
+	// BankFormatCAMT is an ISO 20022 camt.053 statement or camt.054
+	// debit/credit notification.
+	BankFormatCAMT BankFormat = "camt"
+	// BankFormatOFX is an OFX or QFX statement, SGML or XML.
+	BankFormatOFX BankFormat = "ofx"
+	// BankFormatBAI2 is a BAI2 cash management balance report.
+	BankFormatBAI2 BankFormat = "bai2"
+	// BankFormatXLSX is an Excel workbook described by a bank profile like
+	// a CSV export.
+	BankFormatXLSX BankFormat = "xlsx"
+	// BankFormatAuto detects the format from the start of the file.
+	BankFormatAuto BankFormat = "auto"
+)
+
+var bankFormats = []BankFormat{BankFormatCSV, BankFormatMT940, BankFormatCAMT, BankFormatOFX, BankFormatBAI2, BankFormatXLSX, BankFormatAuto}
+
+// ParseBankFormat returns the format named by s, BankFormatAuto when empty.
+func ParseBankFormat(s string) (BankFormat, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return BankFormatAuto, nil
+	}
+	for _, f := range bankFormats {
+		if BankFormat(s) == f {
+			return f, nil
+		}
+	}
+
+	names := make([]string, len(bankFormats))
+	for i, f := range bankFormats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("invalid bank_format %q (expected one of %s)", s, strings.Join(names, ", "))
+}
+
+// bankFormat returns the format requested for the i-th uploaded file,
+// BankFormatAuto when none is.
+func (o ReconcileOptions) bankFormat(i int) BankFormat {
+	format := BankFormat("")
+	switch {
+	case len(o.BankFormats) == 1:
+		format = o.BankFormats[0]
+	case i < len(o.BankFormats):
+		format = o.BankFormats[i]
+	}
+
+	if format == "" {
+		return BankFormatAuto
+	}
+	return format
+}
+
+// sniffSize is how much of a file sniffBankFormat reads, enough to get past
+// the XML declaration and the OFX headers.
+const sniffSize = 1024
+
+// sniffBankFormat tells the format of a file from its first bytes. It returns
+// a reader yielding the whole file again.
+func sniffBankFormat(r io.Reader) (BankFormat, io.Reader) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	head, _ := br.Peek(sniffSize)
+	return detectBankFormat(string(head)), br
+}
+
+// detectBankFormat tells the format of a file from its start.
+func detectBankFormat(head string) BankFormat {
+	head = strings.TrimSpace(strings.TrimPrefix(head, "\ufeff"))
+	switch {
+	case strings.HasPrefix(head, xlsxSignature):
+		return BankFormatXLSX
+	case strings.HasPrefix(head, "{1:") || strings.HasPrefix(head, ":20:") || strings.HasPrefix(head, ":940:"):
+		return BankFormatMT940
+	case strings.HasPrefix(head, "01,"):
+		return BankFormatBAI2
+	case strings.HasPrefix(head, "OFXHEADER") || strings.Contains(head, "<?OFX") || strings.Contains(strings.ToUpper(head), "<OFX>"):
+		return BankFormatOFX
+	case strings.HasPrefix(head, "<"):
+		return BankFormatCAMT
+	}
+	return BankFormatCSV
+}
@@ -0,0 +1,353 @@
+package reconciliation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RuleFX names matches between transactions of different currencies.
+const RuleFX MatchRule = "fx"
+
+// FXRate is the rate converting one unit of From into To, effective from Date
+// until the next rate of the pair.
+type FXRate struct {
+	Date time.Time
+	From string
+	To   string
+	Rate string
+}
+
+type fxEntry struct {
+	date time.Time
+	text string
+	rate *big.Rat
+}
+
+// FXRates is a table of daily exchange rates. The zero value is an empty
+// table.
+type FXRates struct {
+	pairs map[string][]fxEntry
+}
+
+func fxPair(from, to string) string {
+	return from + "/" + to
+}
+
+// Add adds a rate to the table, replacing any rate of the same pair and
+// date.
+func (t *FXRates) Add(rate FXRate) error {
+	from, to := normalizeCurrency(rate.From), normalizeCurrency(rate.To)
+	if !IsCurrencyCode(from) || !IsCurrencyCode(to) || from == to {
+		return fmt.Errorf("invalid currency pair %q/%q", rate.From, rate.To)
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(rate.Rate))
+	if !ok || r.Sign() <= 0 {
+		return fmt.Errorf("invalid rate %q (expected positive decimal)", rate.Rate)
+	}
+
+	if t.pairs == nil {
+		t.pairs = make(map[string][]fxEntry)
+	}
+	key := fxPair(from, to)
+	entry := fxEntry{date: dayOf(rate.Date), text: strings.TrimSpace(rate.Rate), rate: r}
+
+	entries := t.pairs[key]
+	i := sort.Search(len(entries), func(i int) bool { return !entries[i].date.Before(entry.date) })
+	if i < len(entries) && entries[i].date.Equal(entry.date) {
+		entries[i] = entry
+		return nil
+	}
+	entries = append(entries, fxEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	t.pairs[key] = entries
+	return nil
+}
+
+// Merge adds every rate of other to the table, other winning on conflicts.
+func (t *FXRates) Merge(other *FXRates) {
+	if other == nil {
+		return
+	}
+	for key, entries := range other.pairs {
+		from, to, _ := strings.Cut(key, "/")
+		for _, e := range entries {
+			t.Add(FXRate{Date: e.date, From: from, To: to, Rate: e.text})
+		}
+	}
+}
+
+// IsEmpty reports whether the table holds no rate.
+func (t *FXRates) IsEmpty() bool {
+	return t == nil || len(t.pairs) == 0
+}
+
+// lookup returns the rate converting from into to effective on day: the
+// latest rate dated on or before day, or the inverse of the reverse pair
+// when the pair itself is not listed.
+func (t *FXRates) lookup(from, to string, day time.Time) (fxEntry, bool) {
+	if t.IsEmpty() {
+		return fxEntry{}, false
+	}
+	if e, ok := latestOnOrBefore(t.pairs[fxPair(from, to)], day); ok {
+		return e, true
+	}
+	if e, ok := latestOnOrBefore(t.pairs[fxPair(to, from)], day); ok {
+		return fxEntry{date: e.date, text: "1/" + e.text, rate: new(big.Rat).Inv(e.rate)}, true
+	}
+	return fxEntry{}, false
+}
+
+func latestOnOrBefore(entries []fxEntry, day time.Time) (fxEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].date.After(day) })
+	if i == 0 {
+		return fxEntry{}, false
+	}
+	return entries[i-1], true
+}
+
+// convert converts amount, in minor units of from, into minor units of to,
+// rounding half away from zero.
+func (e fxEntry) convert(amount Money, from, to string) Money {
+	v := new(big.Rat).SetInt64(int64(amount))
+	v.Mul(v, e.rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(CurrencyDigits(to)), pow10(CurrencyDigits(from))))
+
+	// round half away from zero
+	num, den := new(big.Int).Set(v.Num()), v.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Lsh(r, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return Money(q.Int64())
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// LoadFXRates reads a rate table from CSV with a date,from,to,rate header, or
+// from a JSON array of {"date","from","to","rate"} objects. Dates are
+// YYYY-MM-DD and rates are decimals, kept exact.
+func LoadFXRates(r io.Reader) (*FXRates, error) {
+	br := bufio.NewReader(r)
+
+	var rates []FXRate
+	if first, err := firstNonSpace(br); err != nil {
+		return nil, err
+	} else if first == '[' {
+		var rows []struct {
+			Date string      `json:"date"`
+			From string      `json:"from"`
+			To   string      `json:"to"`
+			Rate json.Number `json:"rate"`
+		}
+		dec := json.NewDecoder(br)
+		dec.UseNumber()
+		if err := dec.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid fx rates JSON: %v", err)
+		}
+		for i, row := range rows {
+			date, err := time.Parse(BankTimeFormat, row.Date)
+			if err != nil {
+				return nil, fmt.Errorf("fx rate %d: invalid date %q (expected YYYY-MM-DD)", i+1, row.Date)
+			}
+			rates = append(rates, FXRate{Date: date, From: row.From, To: row.To, Rate: row.Rate.String()})
+		}
+	} else {
+		rows, err := newRowReader(newCSVReader(br, ","), "fx_rates")
+		if err != nil {
+			return nil, err
+		}
+		var col struct{ date, from, to, rate int }
+		for _, c := range []struct {
+			ref string
+			idx *int
+		}{{"date", &col.date}, {"from", &col.from}, {"to", &col.to}, {"rate", &col.rate}} {
+			if *c.idx, err = columnIndex(rows.header, c.ref); err != nil {
+				return nil, fmt.Errorf("invalid fx rates CSV: %v", err)
+			}
+		}
+
+		for {
+			record, err := rows.next()
+			if err == io.EOF {
+				break
+			}
+			date, err := time.Parse(BankTimeFormat, cell(record, col.date))
+			if err != nil {
+				return nil, fmt.Errorf("fx rates line %d: invalid date %q (expected YYYY-MM-DD)", rows.line, cell(record, col.date))
+			}
+			rates = append(rates, FXRate{Date: date, From: cell(record, col.from), To: cell(record, col.to), Rate: cell(record, col.rate)})
+		}
+		if len(rows.errs) > 0 {
+			return nil, fmt.Errorf("invalid fx rates CSV: %v", rows.errs[0])
+		}
+	}
+
+	table := &FXRates{}
+	for i, rate := range rates {
+		if err := table.Add(rate); err != nil {
+			return nil, fmt.Errorf("fx rate %d: %v", i+1, err)
+		}
+	}
+	return table, nil
+}
+
+// firstNonSpace skips leading spaces and byte order mark and returns the
+// next rune without consuming it.
+func firstNonSpace(br *bufio.Reader) (rune, error) {
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return 0, fmt.Errorf("empty fx rates file")
+		}
+		if !unicode.IsSpace(r) && r != '\ufeff' {
+			return r, br.UnreadRune()
+		}
+	}
+}
+
+// FXConversion details how a system amount was converted to the bank
+// currency. Difference is the converted amount minus the bank amount, in the
+// bank currency, and is reported apart from discrepancies.
+type FXConversion struct {
+	From            string
+	To              string
+	Rate            string
+	RateDate        time.Time
+	ConvertedAmount Money
+	Difference      Money
+}
+
+// MarshalJSON renders the amounts with the decimals of the bank currency.
+func (c FXConversion) MarshalJSON() ([]byte, error) {
+	type plain FXConversion
+	return json.Marshal(struct {
+		plain
+		ConvertedAmount json.RawMessage
+		Difference      json.RawMessage
+	}{
+		plain(c),
+		json.RawMessage(c.ConvertedAmount.Format(c.To)),
+		json.RawMessage(c.Difference.Format(c.To)),
+	})
+}
+
+// WithFXRates sets the exchange rates used for every request. Rates uploaded
+// with a request take precedence for the same pair and date.
+func WithFXRates(rates *FXRates) Option {
+	return func(s *reconciliationService) {
+		s.fxRates = rates
+	}
+}
+
+// requestFXRates merges the configured rates, the rates given in the options
+// and the uploaded fx_rates file, later sources winning.
+func (s *reconciliationService) requestFXRates(attachement *multipart.Form, given *FXRates) (*FXRates, error) {
+	rates := &FXRates{}
+	rates.Merge(s.fxRates)
+	rates.Merge(given)
+
+	for _, fileHeader := range attachement.File["fx_rates"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open fx_rates: %v", err)
+		}
+		uploaded, err := LoadFXRates(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid fx_rates: %v", err)
+		}
+		rates.Merge(uploaded)
+	}
+	return rates, nil
+}
+
+type fxMatcher struct{}
+
+// FXMatcher pairs system transactions with a bank line in another currency,
+// converting the system amount at the request FXRates rate for the
+// transaction date and accepting bank lines of the same sign within
+// FXTolerance, or at exactly the converted amount when FXTolerance is zero,
+// posted within the settlement window, closest date first. It is disabled
+// without rates.
+func FXMatcher() Matcher {
+	return fxMatcher{}
+}
+
+func (fxMatcher) Name() string {
+	return string(RuleFX)
+}
+
+func (fxMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	if opts.FXRates.IsEmpty() {
+		return nil
+	}
+
+	bankMapByDate := bankIndexByDate(bank)
+	usedBank := make([]bool, len(bank))
+
+	for i, sys := range system {
+		day := dayOf(sys.TransactionTime)
+		found := false
+
+		for _, date := range opts.Window.dates(day) {
+			for _, idx := range bankMapByDate[date.Format(BankTimeFormat)] {
+				b := bank[idx]
+				if usedBank[idx] || b.Currency == sys.Currency || b.Currency == "" || sys.Currency == "" {
+					continue
+				}
+				conversion, ok := convertForBank(opts.FXRates, sys, b)
+				if !ok || (conversion.ConvertedAmount < 0) != (b.Amount < 0) {
+					continue
+				}
+				if !opts.FXTolerance.bounds(b.Currency, conversion.ConvertedAmount, b.Amount) {
+					continue
+				}
+
+				usedBank[idx] = true
+				found = true
+				matches = append(matches, Match{System: []int{i}, Bank: []int{idx}, FX: &conversion})
+				break
+			}
+			if found {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// convertForBank converts the signed system amount to the bank currency at
+// the rate effective on the system transaction date.
+func convertForBank(rates *FXRates, sys SystemTransaction, b BankTransaction) (FXConversion, bool) {
+	rate, ok := rates.lookup(sys.Currency, b.Currency, dayOf(sys.TransactionTime))
+	if !ok {
+		return FXConversion{}, false
+	}
+
+	converted := rate.convert(getSignedAmount(sys), sys.Currency, b.Currency)
+	return FXConversion{
+		From:            sys.Currency,
+		To:              b.Currency,
+		Rate:            rate.text,
+		RateDate:        rate.date,
+		ConvertedAmount: converted,
+		Difference:      converted - b.Amount,
+	}, true
+}
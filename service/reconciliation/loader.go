@@ -0,0 +1,452 @@
+package reconciliation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SystemDataFile names the system export in parse errors.
+const SystemDataFile = "system_data"
+
+// ParseError describes an input row, or a whole file, rejected while loading.
+// Line is one-based and zero for file level errors. Column is the header name
+// of the offending cell when known.
+type ParseError struct {
+	File   string
+	Line   int
+	Column string
+	Value  string
+	Reason string
+}
+
+func (e ParseError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.File, e.Reason)
+	case e.Column == "":
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Reason)
+	}
+	return fmt.Sprintf("%s:%d: column %s: %s %q", e.File, e.Line, e.Column, e.Reason, e.Value)
+}
+
+// StrictModeError is returned by Reconcile in strict mode when any row or file
+// was rejected.
+type StrictModeError struct {
+	Errors []ParseError
+}
+
+func (e *StrictModeError) Error() string {
+	return fmt.Sprintf("strict mode: %d invalid rows, first: %v", len(e.Errors), e.Errors[0])
+}
+
+// recordReader reads one row of cells at a time, like csv.Reader.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+func newCSVReader(r io.Reader, delimiter string) *csv.Reader {
+	csvReader := csv.NewReader(r)
+
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+	if d, _ := utf8.DecodeRuneInString(delimiter); d != utf8.RuneError {
+		csvReader.Comma = d
+	}
+	return csvReader
+}
+
+// rowReader reads the data rows after a header, tracking line numbers and
+// collecting the parse errors of the file.
+type rowReader struct {
+	records recordReader
+	file    string
+	header  []string
+	rows    int
+	line    int
+	errs    []ParseError
+}
+
+func newRowReader(records recordReader, file string) (*rowReader, error) {
+	header, err := records.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &rowReader{records: records, file: file, header: header, rows: 1, line: 1}, nil
+}
+
+// next returns the next well formed row, or io.EOF. Rows the reader rejects
+// are recorded and skipped.
+func (r *rowReader) next() ([]string, error) {
+	for {
+		record, err := r.records.Read()
+		r.rows++
+		if err == io.EOF {
+			return nil, err
+		}
+
+		var csvErr *csv.ParseError
+		switch {
+		case errors.As(err, &csvErr):
+			r.line = csvErr.Line
+			r.errs = append(r.errs, ParseError{File: r.file, Line: csvErr.Line, Reason: csvErr.Err.Error()})
+			continue
+		case err != nil:
+			r.line = r.rows
+			r.errs = append(r.errs, ParseError{File: r.file, Line: r.rows, Reason: err.Error()})
+			continue
+		}
+
+		r.line = r.rows
+		if pos, ok := r.records.(interface{ FieldPos(int) (int, int) }); ok {
+			r.line, _ = pos.FieldPos(0)
+		}
+		return record, nil
+	}
+}
+
+// reject records that the cell at idx of the current row is invalid.
+func (r *rowReader) reject(record []string, idx int, reason string) {
+	column := ""
+	if idx >= 0 && idx < len(r.header) {
+		column = headerName(r.header[idx])
+	}
+	r.errs = append(r.errs, ParseError{File: r.file, Line: r.line, Column: column, Value: cell(record, idx), Reason: reason})
+}
+
+// required returns the cell at idx, rejecting the row when it is empty.
+func (r *rowReader) required(record []string, idx int) (string, bool) {
+	v := cell(record, idx)
+	if v == "" {
+		r.reject(record, idx, "missing value")
+		return "", false
+	}
+	return v, true
+}
+
+// currency returns the currency code at idx, falling back to fallback when
+// the cell is empty, and rejects the row when the code is malformed.
+func (r *rowReader) currency(record []string, idx int, fallback string) (string, bool) {
+	currency := normalizeCurrency(cell(record, idx))
+	if currency == "" {
+		return normalizeCurrency(fallback), true
+	}
+	if !IsCurrencyCode(currency) {
+		r.reject(record, idx, "invalid currency (expected ISO 4217 code)")
+		return "", false
+	}
+	return currency, true
+}
+
+// LoadSystemTransactionsWithProfile loads the system transactions of a CSV
+// export laid out as described by profile. Rows dated outside start and end
+// are skipped; other rows that cannot be parsed are returned as parse errors
+// attributed to file.
+func LoadSystemTransactionsWithProfile(r io.Reader, file string, profile SystemProfile, start, end time.Time) ([]SystemTransaction, []ParseError, error) {
+	profile = profile.withDefaults()
+	var trxs []SystemTransaction
+	parseErrors, err := loadSystemRecords(newCSVReader(r, profile.Delimiter), file, profile, start, end, func(trx SystemTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return trxs, parseErrors, nil
+}
+
+// LoadBankStatementWithProfile loads the lines of a CSV bank statement laid
+// out as described by profile, extracting references according to rule. Rows
+// dated outside start and end are skipped; other rows that cannot be parsed
+// are returned as parse errors attributed to file.
+func LoadBankStatementWithProfile(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule) ([]BankTransaction, []ParseError, error) {
+	profile = profile.withDefaults()
+	var trxs []BankTransaction
+	parseErrors, err := loadBankRecords(newCSVReader(r, profile.Delimiter), file, profile, bankName, start, end, rule, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return trxs, parseErrors, nil
+}
+
+type systemColumnIndex struct {
+	id, amount, trxType, trxTime, currency int
+}
+
+// loadSystemRecords passes every system transaction read from records to
+// emit, in file order, and returns the rows it rejected.
+func loadSystemRecords(records recordReader, file string, profile SystemProfile, start, end time.Time, emit func(SystemTransaction) error) ([]ParseError, error) {
+	rows, err := newRowReader(records, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var col systemColumnIndex
+	for _, c := range []struct {
+		ref string
+		idx *int
+	}{
+		{profile.Columns.TransactionID, &col.id},
+		{profile.Columns.Amount, &col.amount},
+		{profile.Columns.Type, &col.trxType},
+		{profile.Columns.TransactionTime, &col.trxTime},
+		{profile.Columns.Currency, &col.currency},
+	} {
+		if *c.idx, err = columnIndex(rows.header, c.ref); err != nil {
+			return nil, err
+		}
+	}
+
+	layouts, loc := profile.timeLayouts(), locationOrUTC(profile.Location)
+
+	for {
+		record, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+
+		tTime, err := parseTime(cell(record, col.trxTime), layouts, loc)
+		if err != nil {
+			rows.reject(record, col.trxTime, err.Error())
+			continue
+		}
+
+		if tTime.Before(start) || tTime.After(end) {
+			continue
+		}
+
+		id, ok := rows.required(record, col.id)
+		if !ok {
+			continue
+		}
+
+		currency, ok := rows.currency(record, col.currency, profile.Currency)
+		if !ok {
+			continue
+		}
+
+		if _, ok := rows.required(record, col.amount); !ok {
+			continue
+		}
+		amount, err := parseAmount(cell(record, col.amount), currency, profile.amountFormat())
+		if err != nil {
+			rows.reject(record, col.amount, "invalid amount")
+			continue
+		}
+
+		trxType := TransactionType(strings.ToUpper(cell(record, col.trxType)))
+		if trxType != Debit && trxType != Credit {
+			rows.reject(record, col.trxType, "invalid type (expected DEBIT or CREDIT)")
+			continue
+		}
+
+		err = emit(SystemTransaction{
+			TransactionID:   id,
+			Amount:          amount,
+			Currency:        currency,
+			Type:            trxType,
+			TransactionTime: tTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows.errs, nil
+}
+
+type bankColumnIndex struct {
+	uniqueID, amount, debit, credit, drCr, date, description, currency int
+}
+
+// loadBankRecords passes every statement line read from records to emit, in
+// file order, and returns the rows it rejected.
+func loadBankRecords(records recordReader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule, emit func(BankTransaction) error) ([]ParseError, error) {
+	rows, err := newRowReader(records, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var col bankColumnIndex
+	for _, c := range []struct {
+		ref string
+		idx *int
+	}{
+		{profile.Columns.UniqueID, &col.uniqueID},
+		{profile.Columns.Amount, &col.amount},
+		{profile.Columns.Debit, &col.debit},
+		{profile.Columns.Credit, &col.credit},
+		{profile.Columns.DrCr, &col.drCr},
+		{profile.Columns.Date, &col.date},
+		{profile.Columns.Description, &col.description},
+		{profile.Columns.Currency, &col.currency},
+	} {
+		if *c.idx, err = columnIndex(rows.header, c.ref); err != nil {
+			return nil, err
+		}
+	}
+
+	refExtractor, err := newReferenceExtractor(rule, rows.header)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		record, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+
+		dTime, err := time.ParseInLocation(profile.DateLayout, cell(record, col.date), locationOrUTC(profile.Location))
+		if err != nil {
+			rows.reject(record, col.date, fmt.Sprintf("invalid date (expected layout %s)", profile.DateLayout))
+			continue
+		}
+
+		// statement dates are calendar days, whatever the zone of the period
+		if day := dayOf(dTime); day.Before(dayOf(start)) || day.After(dayOf(end)) {
+			continue
+		}
+
+		uniqueID, ok := rows.required(record, col.uniqueID)
+		if !ok {
+			continue
+		}
+
+		currency, ok := rows.currency(record, col.currency, profile.Currency)
+		if !ok {
+			continue
+		}
+
+		amount, ok := bankAmount(rows, record, col, currency, profile)
+		if !ok {
+			continue
+		}
+
+		description, reference := refExtractor.extract(record)
+		if col.description >= 0 {
+			description = cell(record, col.description)
+		}
+
+		err = emit(BankTransaction{
+			BankName:    bankName,
+			UniqueID:    uniqueID,
+			Amount:      amount,
+			Currency:    currency,
+			Date:        dTime,
+			Description: description,
+			Reference:   reference,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows.errs, nil
+}
+
+// bankAmount returns the signed amount of a statement row according to the
+// profile sign convention, rejecting the row when it cannot be parsed.
+func bankAmount(rows *rowReader, record []string, col bankColumnIndex, currency string, profile BankProfile) (Money, bool) {
+	amountAt := func(idx int) (Money, bool) {
+		amount, err := parseAmount(cell(record, idx), currency, profile.amountFormat())
+		if err != nil {
+			rows.reject(record, idx, "invalid amount")
+			return 0, false
+		}
+		return amount, true
+	}
+
+	switch profile.AmountSign {
+	case DebitCreditColumns:
+		if cell(record, col.debit) == "" && cell(record, col.credit) == "" {
+			rows.reject(record, col.debit, "missing value")
+			return 0, false
+		}
+		debit, ok := amountAt(col.debit)
+		if !ok {
+			return 0, false
+		}
+		credit, ok := amountAt(col.credit)
+		if !ok {
+			return 0, false
+		}
+		if debit != 0 && credit != 0 {
+			rows.reject(record, col.credit, "both debit and credit set (expected only one)")
+			return 0, false
+		}
+		return absMoney(credit) - absMoney(debit), true
+
+	case DrCrFlag:
+		if _, ok := rows.required(record, col.amount); !ok {
+			return 0, false
+		}
+		amount, ok := amountAt(col.amount)
+		if !ok {
+			return 0, false
+		}
+		switch flag := cell(record, col.drCr); {
+		case strings.EqualFold(flag, profile.DebitFlag):
+			return -absMoney(amount), true
+		case strings.EqualFold(flag, profile.CreditFlag):
+			return absMoney(amount), true
+		}
+		rows.reject(record, col.drCr, fmt.Sprintf("invalid debit/credit flag (expected %s or %s)", profile.DebitFlag, profile.CreditFlag))
+		return 0, false
+
+	default:
+		if _, ok := rows.required(record, col.amount); !ok {
+			return 0, false
+		}
+		return amountAt(col.amount)
+	}
+}
+
+// parseAmount parses an amount cell into minor units of currency. An empty
+// cell is zero.
+func parseAmount(value, currency string, format AmountFormat) (Money, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return ParseCurrencyMoney(value, currency, format)
+}
+
+// columnIndex resolves a column reference against the header row. It returns
+// -1 for an empty reference.
+func columnIndex(header []string, ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1, nil
+	}
+
+	for i, h := range header {
+		if strings.EqualFold(headerName(h), ref) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(header) {
+		return i, nil
+	}
+	return -1, fmt.Errorf("column %q not found", ref)
+}
+
+// headerName strips the spaces and byte order mark spreadsheets leave around
+// header cells.
+func headerName(h string) string {
+	return strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+}
+
+// cell returns the trimmed value at idx, or an empty string when the row is
+// too short.
+func cell(record []string, idx int) string {
+	if idx < 0 || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
@@ -0,0 +1,293 @@
+package reconciliation
+
+// Matcher is one stage of the matching pipeline. Match receives the system
+// transactions and bank lines left unmatched by the previous stages and
+// returns the matches it finds among them. Matches must not share
+// transactions; overlapping or out of range matches are ignored.
+type Matcher interface {
+	Name() string
+	Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) []Match
+}
+
+// Match refers to transactions by their index in the slices given to
+// Matcher.Match. One index on each side is a one-to-one match, anything more
+// is reported as a group match. Rule defaults to the matcher name. A
+// one-to-one match across currencies must carry the FX conversion used, and
+// one whose bank line is net of a bank fee the fee deducted.
+type Match struct {
+	System []int
+	Bank   []int
+	Rule   MatchRule
+	FX     *FXConversion
+	Fee    Money
+}
+
+// DefaultMatchers returns the built-in matching pipeline.
+func DefaultMatchers() []Matcher {
+	return []Matcher{
+		ReferenceMatcher(),
+		ExactKeyMatcher(),
+		SplitMatcher(),
+		FeeMatcher(),
+		DiscrepancyMatcher(),
+		FXMatcher(),
+	}
+}
+
+// runMatchers runs the pipeline in order and returns the transactions no
+// matcher could pair.
+func runMatchers(matchers []Matcher, result *ReconciliationResult, system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) ([]SystemTransaction, []BankTransaction) {
+	for _, m := range matchers {
+		if len(system) == 0 || len(bank) == 0 {
+			break
+		}
+
+		usedSystem := make([]bool, len(system))
+		usedBank := make([]bool, len(bank))
+
+		for _, match := range m.Match(system, bank, opts) {
+			if !claimMatch(match, system, bank, usedSystem, usedBank) {
+				continue
+			}
+
+			if match.Rule == "" {
+				match.Rule = MatchRule(m.Name())
+			}
+
+			if len(match.System) == 1 && len(match.Bank) == 1 {
+				recordMatch(result, system[match.System[0]], bank[match.Bank[0]], match)
+				continue
+			}
+
+			group := GroupMatch{Rule: match.Rule}
+			for _, i := range match.System {
+				group.System = append(group.System, system[i])
+			}
+			for _, i := range match.Bank {
+				group.Bank = append(group.Bank, bank[i])
+			}
+			group.Confidence = groupConfidence(group)
+			result.GroupMatches = append(result.GroupMatches, group)
+		}
+
+		system = unclaimed(system, usedSystem)
+		bank = unclaimed(bank, usedBank)
+	}
+
+	return system, bank
+}
+
+// singleCurrency reports whether all transactions of a match share one
+// currency, or the match is a one-to-one match converted between its two
+// currencies. Other matches across currencies are never recorded.
+func singleCurrency(match Match, system []SystemTransaction, bank []BankTransaction) bool {
+	currency := system[match.System[0]].Currency
+	if match.FX != nil {
+		return len(match.System) == 1 && len(match.Bank) == 1 &&
+			match.FX.From == currency && match.FX.To == bank[match.Bank[0]].Currency
+	}
+	for _, i := range match.System {
+		if system[i].Currency != currency {
+			return false
+		}
+	}
+	for _, i := range match.Bank {
+		if bank[i].Currency != currency {
+			return false
+		}
+	}
+	return true
+}
+
+// claimMatch marks the transactions of match as used. It refuses matches that
+// are empty on either side, out of range, overlap an earlier match or span
+// several currencies without conversion.
+func claimMatch(match Match, system []SystemTransaction, bank []BankTransaction, usedSystem, usedBank []bool) bool {
+	if len(match.System) == 0 || len(match.Bank) == 0 {
+		return false
+	}
+
+	seenSystem := make(map[int]bool)
+	for _, i := range match.System {
+		if i < 0 || i >= len(usedSystem) || usedSystem[i] || seenSystem[i] {
+			return false
+		}
+		seenSystem[i] = true
+	}
+	seenBank := make(map[int]bool)
+	for _, i := range match.Bank {
+		if i < 0 || i >= len(usedBank) || usedBank[i] || seenBank[i] {
+			return false
+		}
+		seenBank[i] = true
+	}
+	if !singleCurrency(match, system, bank) {
+		return false
+	}
+
+	for _, i := range match.System {
+		usedSystem[i] = true
+	}
+	for _, i := range match.Bank {
+		usedBank[i] = true
+	}
+	return true
+}
+
+func unclaimed[T any](items []T, used []bool) (rest []T) {
+	for i, item := range items {
+		if !used[i] {
+			rest = append(rest, item)
+		}
+	}
+	return rest
+}
+
+// bankIndexByDate indexes bank lines by posting date.
+func bankIndexByDate(bank []BankTransaction) map[string][]int {
+	byDate := make(map[string][]int)
+	for i, b := range bank {
+		dKey := b.Date.Format(BankTimeFormat)
+		byDate[dKey] = append(byDate[dKey], i)
+	}
+	return byDate
+}
+
+type referenceMatcher struct{}
+
+// ReferenceMatcher pairs system transactions with the bank line whose
+// extracted reference equals the transaction ID, regardless of date and
+// amount.
+func ReferenceMatcher() Matcher {
+	return referenceMatcher{}
+}
+
+func (referenceMatcher) Name() string {
+	return string(RuleReference)
+}
+
+func (referenceMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	bankMapByRef := make(map[string][]int)
+	for i, b := range bank {
+		if b.Reference != "" {
+			bankMapByRef[b.Reference] = append(bankMapByRef[b.Reference], i)
+		}
+	}
+	if len(bankMapByRef) == 0 {
+		return nil
+	}
+
+	usedBank := make([]bool, len(bank))
+	for i, sys := range system {
+		for _, idx := range bankMapByRef[sys.TransactionID] {
+			if !usedBank[idx] && bank[idx].Currency == sys.Currency {
+				usedBank[idx] = true
+				matches = append(matches, Match{System: []int{i}, Bank: []int{idx}})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+type exactKeyMatcher struct{}
+
+// ExactKeyMatcher pairs system transactions with a bank line of the same
+// currency and signed amount posted within the settlement window, closest
+// date first.
+func ExactKeyMatcher() Matcher {
+	return exactKeyMatcher{}
+}
+
+func (exactKeyMatcher) Name() string {
+	return string(RuleExactKey)
+}
+
+func (exactKeyMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	bankMap := make(map[string][]int)
+	for i, b := range bank {
+		key := b.Currency + " " + generateKey(b.Date, b.Amount)
+		bankMap[key] = append(bankMap[key], i)
+	}
+
+	usedBank := make([]bool, len(bank))
+	for i, sys := range system {
+		finalAmount := getSignedAmount(sys)
+		matched := false
+
+		// closest posting date first
+		for _, date := range opts.Window.dates(dayOf(sys.TransactionTime)) {
+			for _, idx := range bankMap[sys.Currency+" "+generateKey(date, finalAmount)] {
+				if !usedBank[idx] {
+					usedBank[idx] = true
+					matched = true
+					matches = append(matches, Match{System: []int{i}, Bank: []int{idx}})
+					break
+				}
+			}
+			if matched {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+type discrepancyMatcher struct{}
+
+// DiscrepancyMatcher pairs the remaining system transactions with any bank
+// line posted within the settlement window whose amount is within tolerance.
+// The pairing is first-fit or a minimum-cost assignment depending on the
+// request MatchMode.
+func DiscrepancyMatcher() Matcher {
+	return discrepancyMatcher{}
+}
+
+func (discrepancyMatcher) Name() string {
+	return string(RuleSameDayDiscrepancy)
+}
+
+func (discrepancyMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	if opts.Mode == MatchModeOptimal {
+		assigned := assignOptimal(system, bank, opts)
+		for i := range system {
+			if idx, ok := assigned[i]; ok {
+				matches = append(matches, Match{System: []int{i}, Bank: []int{idx}})
+			}
+		}
+		return matches
+	}
+
+	return firstFit(system, bank, opts.Window, func(sys SystemTransaction, b BankTransaction) bool {
+		// bank lines beyond the tolerance stay unmatched
+		return b.Currency == sys.Currency && opts.toleranceFor(b.BankName).AllowsIn(sys.Currency, getSignedAmount(sys), b.Amount)
+	})
+}
+
+// firstFit pairs each system transaction with the first accepted bank line
+// posted within the window, closest date first.
+func firstFit(system []SystemTransaction, bank []BankTransaction, window SettlementWindow, accept func(SystemTransaction, BankTransaction) bool) (matches []Match) {
+	bankMapByDate := bankIndexByDate(bank)
+	usedBank := make([]bool, len(bank))
+
+	for i, sys := range system {
+		found := false
+
+		for _, date := range window.dates(dayOf(sys.TransactionTime)) {
+			for _, idx := range bankMapByDate[date.Format(BankTimeFormat)] {
+				if usedBank[idx] || !accept(sys, bank[idx]) {
+					continue
+				}
+
+				usedBank[idx] = true
+				found = true
+				matches = append(matches, Match{System: []int{i}, Bank: []int{idx}})
+				break
+			}
+			if found {
+				break
+			}
+		}
+	}
+	return matches
+}
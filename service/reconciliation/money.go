@@ -0,0 +1,225 @@
+package reconciliation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// moneyDigits is the number of decimal digits held by Money when no
+// currency is given.
+const moneyDigits = 2
+
+// currencyDigits lists the currencies whose minor unit is not a hundredth.
+var currencyDigits = map[string]int{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"BHD": 3,
+	"IQD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// CurrencyDigits returns the number of decimal digits of the minor unit of
+// the ISO 4217 currency code. Money of that currency counts minor units.
+func CurrencyDigits(currency string) int {
+	if d, ok := currencyDigits[normalizeCurrency(currency)]; ok {
+		return d
+	}
+	return moneyDigits
+}
+
+func normalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// IsCurrencyCode tells whether currency looks like an upper case ISO 4217
+// code.
+func IsCurrencyCode(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Format writes m as a decimal amount of currency, e.g. 1234.50 for USD or
+// 1234 for IDR.
+func (m Money) Format(currency string) string {
+	digits := CurrencyDigits(currency)
+
+	sign, units := "", int64(m)
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	s := strconv.FormatInt(units, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+// rescale converts m from minor units with fromDigits decimals to minor units
+// with toDigits decimals, dropping the extra precision.
+func (m Money) rescale(fromDigits, toDigits int) Money {
+	for ; fromDigits < toDigits; fromDigits++ {
+		m *= 10
+	}
+	for ; fromDigits > toDigits; fromDigits-- {
+		m /= 10
+	}
+	return m
+}
+
+// RoundingMode tells how an amount written with more decimals than Money
+// holds is brought to minor units.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero.
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the even neighbour.
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundDown drops the extra decimals, rounding toward zero.
+	RoundDown RoundingMode = "down"
+	// RoundExact rejects amounts whose extra decimals are not all zero.
+	RoundExact RoundingMode = "exact"
+)
+
+// Validate checks the rounding mode is known. The zero value is valid and
+// means RoundHalfUp.
+func (m RoundingMode) Validate() error {
+	switch m {
+	case "", RoundHalfUp, RoundHalfEven, RoundDown, RoundExact:
+		return nil
+	}
+	return fmt.Errorf("unknown rounding mode %q (expected half_up, half_even, down or exact)", m)
+}
+
+// AmountFormat describes how amounts are written in a file. The zero value
+// reads plain amounts such as 1234567.89, rounding half up.
+type AmountFormat struct {
+	DecimalSeparator   string
+	ThousandsSeparator string
+	Rounding           RoundingMode
+}
+
+// ParseMoney parses an amount written in format into Money, using exact
+// decimal arithmetic. A leading + or -, a trailing - or enclosing parentheses
+// give the sign, so 1.234.567,89- reads as -1234567.89 with Indonesian
+// separators.
+func ParseMoney(value string, format AmountFormat) (Money, error) {
+	return parseMoney(value, format, moneyDigits)
+}
+
+// ParseCurrencyMoney parses an amount like ParseMoney, into the minor units of
+// currency.
+func ParseCurrencyMoney(value, currency string, format AmountFormat) (Money, error) {
+	return parseMoney(value, format, CurrencyDigits(currency))
+}
+
+func parseMoney(value string, format AmountFormat, digits int) (Money, error) {
+	decimalSep := format.DecimalSeparator
+	if decimalSep == "" {
+		decimalSep = "."
+	}
+
+	s := strings.TrimSpace(value)
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")"):
+		s, negative = s[1:len(s)-1], true
+	case strings.HasSuffix(s, "-"):
+		s, negative = s[:len(s)-1], true
+	case strings.HasPrefix(s, "-"):
+		s, negative = s[1:], true
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	s = strings.TrimSpace(s)
+
+	intPart, fracPart, _ := strings.Cut(s, decimalSep)
+	if format.ThousandsSeparator != "" {
+		if strings.Contains(fracPart, format.ThousandsSeparator) {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+		intPart = strings.ReplaceAll(intPart, format.ThousandsSeparator, "")
+	}
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	kept, extra := fracPart, ""
+	if len(kept) > digits {
+		kept, extra = fracPart[:digits], fracPart[digits:]
+	}
+	kept += strings.Repeat("0", digits-len(kept))
+
+	var units int64
+	for _, c := range intPart + kept {
+		d := int64(c - '0')
+		if units > (math.MaxInt64-d)/10 {
+			return 0, fmt.Errorf("amount %q out of range", value)
+		}
+		units = units*10 + d
+	}
+
+	if roundUp, err := roundsUp(units, extra, digits, format.Rounding); err != nil {
+		return 0, fmt.Errorf("amount %q: %v", value, err)
+	} else if roundUp {
+		if units == math.MaxInt64 {
+			return 0, fmt.Errorf("amount %q out of range", value)
+		}
+		units++
+	}
+
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+// roundsUp tells whether the absolute amount units, followed by the dropped
+// decimals extra, rounds away from zero.
+func roundsUp(units int64, extra string, digits int, mode RoundingMode) (bool, error) {
+	rest := strings.TrimRight(extra, "0")
+	if rest == "" {
+		return false, nil
+	}
+
+	switch mode {
+	case RoundDown:
+		return false, nil
+	case RoundExact:
+		return false, fmt.Errorf("more than %d decimals", digits)
+	case RoundHalfEven:
+		if rest == "5" {
+			return units%2 == 1, nil
+		}
+	}
+	return rest[0] >= '5', nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,276 @@
+package reconciliation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mt940Columns names the parts of a statement line a ReferenceRule may read:
+// the account owner reference of :61: and the :86: information.
+var mt940Columns = []string{"reference", "description"}
+
+var (
+	// value date, entry date, mark, funds code, amount, transaction type,
+	// then the references
+	mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d[\d,]*)([NFS][A-Z0-9]{3})(.*)$`)
+	// mark, date, currency, amount
+	mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d[\d,]*)$`)
+	mt940Tag     = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+)
+
+// mt940Field is one tagged field with its continuation lines.
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// LoadMT940Statement loads the statement lines of a SWIFT MT940 file, in file
+// order, with the statements it holds. Lines valued outside start and end are
+// skipped; other lines that cannot be parsed are returned as parse errors
+// attributed to file. The statement currency applies to every line, the
+// profile currency when a statement reports no balance first.
+func LoadMT940Statement(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule) ([]BankTransaction, []Statement, []ParseError, error) {
+	var trxs []BankTransaction
+	statements, parseErrors, err := loadMT940(r, file, profile, bankName, start, end, rule, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return trxs, statements, parseErrors, nil
+}
+
+// loadMT940 passes every statement line of an MT940 file to emit, in file
+// order, and returns the statements read and the lines it rejected.
+func loadMT940(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule, emit func(BankTransaction) error) ([]Statement, []ParseError, error) {
+	refExtractor, err := newReferenceExtractor(rule, mt940Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := locationOrUTC(profile.Location)
+	amountFormat := AmountFormat{DecimalSeparator: ",", Rounding: profile.Rounding}
+
+	var (
+		statements  []Statement
+		parseErrors []ParseError
+		current     *Statement
+		currency    string
+		pending     *BankTransaction
+	)
+
+	reject := func(f mt940Field, reason string) {
+		parseErrors = append(parseErrors, ParseError{File: file, Line: f.line, Column: ":" + f.tag + ":", Value: f.value, Reason: reason})
+	}
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		trx := *pending
+		pending = nil
+		if refExtractor != nil {
+			_, trx.Reference = refExtractor.extract([]string{trx.Reference, trx.Description})
+		}
+		return emit(trx)
+	}
+	balance := func(f mt940Field) (*StatementBalance, bool) {
+		m := mt940Balance.FindStringSubmatch(strings.TrimSpace(f.value))
+		if m == nil {
+			reject(f, "invalid balance (expected mark, YYMMDD date, currency and amount)")
+			return nil, false
+		}
+		date, err := time.ParseInLocation("060102", m[2], loc)
+		if err != nil {
+			reject(f, "invalid balance date (expected YYMMDD)")
+			return nil, false
+		}
+		amount, err := ParseCurrencyMoney(m[4], m[3], amountFormat)
+		if err != nil {
+			reject(f, "invalid amount")
+			return nil, false
+		}
+		if m[1] == "D" {
+			amount = -amount
+		}
+		currency = m[3]
+		return &StatementBalance{Date: date, Currency: m[3], Amount: amount}, true
+	}
+
+	var prevTag string
+	err = readMT940Fields(r, func(f mt940Field) error {
+		tag := f.tag
+		if tag != "86" || prevTag != "61" {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		if tag == "20" {
+			statements = append(statements, Statement{Reference: strings.TrimSpace(f.value)})
+			current = &statements[len(statements)-1]
+			currency = ""
+			prevTag = tag
+			return nil
+		}
+		if current == nil {
+			// fields before the first :20: belong to no statement
+			statements = append(statements, Statement{})
+			current = &statements[len(statements)-1]
+		}
+
+		switch tag {
+		case "25":
+			current.Account = strings.TrimSpace(f.value)
+		case "28C":
+			current.Number = strings.TrimSpace(f.value)
+		case "60F":
+			current.OpeningBalance, _ = balance(f)
+		case "62F":
+			current.ClosingBalance, _ = balance(f)
+		case "60M", "62M":
+			balance(f)
+		case "61":
+			trx, ok := parseMT940Line(f, currency, profile, amountFormat, reject)
+			if !ok {
+				break
+			}
+			// statement dates are calendar days, whatever the zone of the period
+			if day := dayOf(trx.Date); day.Before(dayOf(start)) || day.After(dayOf(end)) {
+				break
+			}
+			trx.BankName = bankName
+			if trx.UniqueID == "" {
+				trx.UniqueID = fmt.Sprintf("%s:%d", file, f.line)
+			}
+			pending = &trx
+		case "86":
+			if pending != nil {
+				pending.Description = f.value
+			}
+		}
+		prevTag = tag
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, nil, err
+	}
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("no MT940 statement found")
+	}
+
+	return statements, parseErrors, nil
+}
+
+// parseMT940Line reads a :61: statement line. The reference is the account
+// owner reference, unless NONREF, and the unique ID the bank reference,
+// falling back to the account owner reference.
+func parseMT940Line(f mt940Field, currency string, profile BankProfile, format AmountFormat, reject func(mt940Field, string)) (BankTransaction, bool) {
+	first, _, _ := strings.Cut(f.value, "\n")
+	m := mt940Line.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		reject(f, "invalid statement line (expected value date, mark, amount and transaction type)")
+		return BankTransaction{}, false
+	}
+
+	date, err := time.ParseInLocation("060102", m[1], locationOrUTC(profile.Location))
+	if err != nil {
+		reject(f, "invalid value date (expected YYMMDD)")
+		return BankTransaction{}, false
+	}
+
+	if currency == "" {
+		currency = normalizeCurrency(profile.Currency)
+	}
+	amount, err := ParseCurrencyMoney(m[5], currency, format)
+	if err != nil {
+		reject(f, "invalid amount")
+		return BankTransaction{}, false
+	}
+	// a reversed credit is a debit and a reversed debit a credit
+	if m[3] == "D" || m[3] == "RC" {
+		amount = -amount
+	}
+
+	ownerRef, bankRef, _ := strings.Cut(m[7], "//")
+	ownerRef, bankRef = strings.TrimSpace(ownerRef), strings.TrimSpace(bankRef)
+	if strings.EqualFold(ownerRef, "NONREF") {
+		ownerRef = ""
+	}
+
+	uniqueID := bankRef
+	if uniqueID == "" {
+		uniqueID = ownerRef
+	}
+	return BankTransaction{UniqueID: uniqueID, Amount: amount, Currency: currency, Date: date, Reference: ownerRef}, true
+}
+
+// readMT940Fields splits an MT940 file into tagged fields, joining
+// continuation lines. The SWIFT block envelope and the message terminators
+// are skipped.
+func readMT940Fields(r io.Reader, field func(mt940Field) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		current *mt940Field
+		lineNo  int
+	)
+	emit := func() error {
+		if current == nil {
+			return nil
+		}
+		f := *current
+		current = nil
+		return field(f)
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		// {1:...}{2:...}{4: opens the text block, -} closes it
+		if strings.HasPrefix(line, "{") {
+			if _, text, ok := strings.Cut(line, "{4:"); ok {
+				line = text
+			} else {
+				continue
+			}
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "-" || strings.HasPrefix(trimmed, "-}") {
+			if err := emit(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if m := mt940Tag.FindStringSubmatch(line); m != nil {
+			if err := emit(); err != nil {
+				return err
+			}
+			current = &mt940Field{tag: m[1], value: m[2], line: lineNo}
+			continue
+		}
+		if current != nil && strings.TrimSpace(line) != "" {
+			if current.tag == "86" {
+				current.value += " " + strings.TrimSpace(line)
+			} else {
+				current.value += "\n" + line
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return emit()
+}
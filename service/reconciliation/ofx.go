@@ -0,0 +1,304 @@
+package reconciliation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxColumns names the parts of a transaction a ReferenceRule may read: the
+// REFNUM and the NAME and MEMO description.
+var ofxColumns = []string{"reference", "description"}
+
+var ofxUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ", "&amp;", "&")
+
+const (
+	ofxOpen = iota
+	ofxClose
+	ofxText
+)
+
+// ofxTransaction collects the elements of one STMTTRN aggregate.
+type ofxTransaction struct {
+	line   int
+	fields map[string]string
+}
+
+// LoadOFXStatement loads the transactions of an OFX or QFX bank or credit
+// card statement, in the SGML (OFX 1.x) or XML (OFX 2.x) variant, with the
+// statements it holds. Transactions posted outside start and end are skipped;
+// other transactions that cannot be parsed are returned as parse errors
+// attributed to file.
+func LoadOFXStatement(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule) ([]BankTransaction, []Statement, []ParseError, error) {
+	var trxs []BankTransaction
+	statements, parseErrors, err := loadOFX(r, file, profile, bankName, start, end, rule, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return trxs, statements, parseErrors, nil
+}
+
+// loadOFX passes every STMTTRN of an OFX file to emit, in file order, and
+// returns the statements read and the transactions it rejected.
+func loadOFX(r io.Reader, file string, profile BankProfile, bankName string, start, end time.Time, rule ReferenceRule, emit func(BankTransaction) error) ([]Statement, []ParseError, error) {
+	refExtractor, err := newReferenceExtractor(rule, ofxColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := locationOrUTC(profile.Location)
+
+	var (
+		statements  []Statement
+		parseErrors []ParseError
+		current     *Statement
+		currency    string
+		trn         *ofxTransaction
+		ledger      map[string]string
+		leaf        string
+		inCurrency  bool
+	)
+
+	reject := func(line int, column, value, reason string) {
+		parseErrors = append(parseErrors, ParseError{File: file, Line: line, Column: column, Value: value, Reason: reason})
+	}
+
+	finish := func(t *ofxTransaction) error {
+		f := t.fields
+		trxCurrency := currency
+		if f["CURSYM"] != "" {
+			trxCurrency = normalizeCurrency(f["CURSYM"])
+		}
+		if trxCurrency == "" {
+			trxCurrency = normalizeCurrency(profile.Currency)
+		}
+
+		date, err := parseOFXDate(f["DTPOSTED"], loc)
+		if err != nil {
+			reject(t.line, "DTPOSTED", f["DTPOSTED"], err.Error())
+			return nil
+		}
+		// statement dates are calendar days, whatever the zone of the period
+		if day := dayOf(date); day.Before(dayOf(start)) || day.After(dayOf(end)) {
+			return nil
+		}
+
+		if f["FITID"] == "" {
+			reject(t.line, "FITID", "", "missing value")
+			return nil
+		}
+		if trxCurrency != "" && !IsCurrencyCode(trxCurrency) {
+			reject(t.line, "CURSYM", trxCurrency, "invalid currency (expected ISO 4217 code)")
+			return nil
+		}
+		amount, err := parseOFXAmount(f["TRNAMT"], trxCurrency, profile.Rounding)
+		if err != nil {
+			reject(t.line, "TRNAMT", f["TRNAMT"], "invalid amount")
+			return nil
+		}
+
+		description := strings.TrimSpace(strings.Join([]string{f["NAME"], f["MEMO"]}, " "))
+		trx := BankTransaction{
+			BankName:    bankName,
+			UniqueID:    f["FITID"],
+			Amount:      amount,
+			Currency:    trxCurrency,
+			Date:        date,
+			Description: description,
+		}
+		if refExtractor != nil {
+			_, trx.Reference = refExtractor.extract([]string{f["REFNUM"], description})
+		}
+		return emit(trx)
+	}
+
+	err = readOFX(r, func(kind int, name, value string, line int) error {
+		switch kind {
+		case ofxOpen:
+			leaf = name
+			switch name {
+			case "STMTRS", "CCSTMTRS":
+				statements = append(statements, Statement{})
+				current = &statements[len(statements)-1]
+				currency = ""
+			case "STMTTRN":
+				trn = &ofxTransaction{line: line, fields: make(map[string]string)}
+			case "CURRENCY", "ORIGCURRENCY":
+				// amounts are in CURRENCY, or in CURDEF converted from ORIGCURRENCY
+				inCurrency = name == "CURRENCY"
+			case "LEDGERBAL":
+				ledger = make(map[string]string)
+			}
+		case ofxClose:
+			leaf = ""
+			switch name {
+			case "STMTTRN":
+				if trn != nil {
+					t := trn
+					trn = nil
+					return finish(t)
+				}
+			case "CURRENCY", "ORIGCURRENCY":
+				inCurrency = false
+			case "LEDGERBAL":
+				if current != nil && ledger != nil {
+					balance, err := ofxBalance(ledger, currency, profile, loc)
+					if err != nil {
+						reject(line, "LEDGERBAL", ledger["BALAMT"], err.Error())
+					} else {
+						current.ClosingBalance = balance
+					}
+				}
+				ledger = nil
+			}
+		case ofxText:
+			name, leaf = leaf, ""
+			switch {
+			case name == "":
+			case trn != nil:
+				if name != "CURSYM" || inCurrency {
+					trn.fields[name] = value
+				}
+			case ledger != nil:
+				ledger[name] = value
+			case current == nil:
+			case name == "CURDEF":
+				currency = normalizeCurrency(value)
+			case name == "ACCTID":
+				current.Account = value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("no OFX statement found")
+	}
+
+	return statements, parseErrors, nil
+}
+
+// readOFX tokenizes an OFX file into opening tags, closing tags and element
+// values, passing each with its line to token. It reads both the SGML
+// variant, whose elements are not closed, and the XML variant. Headers,
+// processing instructions and comments are skipped.
+func readOFX(r io.Reader, token func(kind int, name, value string, line int) error) error {
+	br := bufio.NewReader(r)
+	line := 1
+	var text strings.Builder
+
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c != '<' {
+			if c == '\n' {
+				line++
+			}
+			text.WriteByte(c)
+			continue
+		}
+
+		if value := strings.TrimSpace(text.String()); value != "" {
+			if err := token(ofxText, "", ofxUnescaper.Replace(value), line); err != nil {
+				return err
+			}
+		}
+		text.Reset()
+
+		tagLine := line
+		tag, err := br.ReadString('>')
+		if err != nil {
+			return fmt.Errorf("line %d: unterminated tag", tagLine)
+		}
+		line += strings.Count(tag, "\n")
+		tag = strings.TrimSpace(strings.TrimSuffix(tag, ">"))
+
+		switch {
+		case strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!"):
+		case strings.HasPrefix(tag, "/"):
+			if err := token(ofxClose, strings.ToUpper(strings.TrimSpace(tag[1:])), "", tagLine); err != nil {
+				return err
+			}
+		default:
+			name, _, _ := strings.Cut(strings.TrimSuffix(tag, "/"), " ")
+			if err := token(ofxOpen, strings.ToUpper(name), "", tagLine); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseOFXDate reads an OFX date time such as 20250115, 20250115103000 or
+// 20250115103000.000[+7:WIB] and returns its calendar day in loc. A time
+// without offset is read in loc.
+func parseOFXDate(value string, loc *time.Location) (time.Time, error) {
+	invalid := fmt.Errorf("invalid date (expected YYYYMMDDHHMMSS)")
+
+	value = strings.TrimSpace(value)
+	zone := loc
+	if i := strings.Index(value, "["); i >= 0 {
+		tz := strings.TrimSuffix(value[i+1:], "]")
+		value = value[:i]
+		offset, name, _ := strings.Cut(tz, ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		zone = time.FixedZone(name, int(hours*3600))
+	}
+	if i := strings.Index(value, "."); i >= 0 {
+		value = value[:i]
+	}
+
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, invalid
+	}
+	t, err := time.ParseInLocation(layout, value, zone)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// parseOFXAmount reads a signed OFX amount, whose decimal separator is a
+// point or, in some locales, a comma.
+func parseOFXAmount(value, currency string, rounding RoundingMode) (Money, error) {
+	format := AmountFormat{Rounding: rounding}
+	if strings.Contains(value, ",") && !strings.Contains(value, ".") {
+		format.DecimalSeparator = ","
+	}
+	return ParseCurrencyMoney(value, currency, format)
+}
+
+func ofxBalance(fields map[string]string, currency string, profile BankProfile, loc *time.Location) (*StatementBalance, error) {
+	amount, err := parseOFXAmount(fields["BALAMT"], currency, profile.Rounding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount")
+	}
+	date, err := parseOFXDate(fields["DTASOF"], loc)
+	if err != nil {
+		return nil, err
+	}
+	return &StatementBalance{Date: date, Currency: currency, Amount: amount}, nil
+}
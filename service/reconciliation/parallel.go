@@ -0,0 +1,165 @@
+package reconciliation
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// WithWorkers sets how many goroutines match the independent day blocks of a
+// request concurrently. Values below one use GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(s *reconciliationService) {
+		if n < 1 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		s.workers = n
+	}
+}
+
+// dayBlock holds the transactions of consecutive days that no match can pair
+// with the transactions of another block.
+type dayBlock struct {
+	system []SystemTransaction
+	bank   []BankTransaction
+	result ReconciliationResult
+}
+
+// matchBlocks runs the pipeline like runMatchers, but splits the transactions
+// into day blocks and matches them on up to opts.Workers goroutines. Leading
+// reference stages, which pair regardless of date, first run over all
+// transactions. A pipeline with any other stage not bounded by a settlement
+// window runs sequentially.
+//
+// Matches are merged in block order and the unmatched transactions keep their
+// input order, so the outcome does not depend on scheduling.
+func matchBlocks(matchers []Matcher, result *ReconciliationResult, system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) ([]SystemTransaction, []BankTransaction) {
+	global := 0
+	for global < len(matchers) {
+		if _, ok := matchers[global].(referenceMatcher); !ok {
+			break
+		}
+		global++
+	}
+
+	windows, ok := matcherWindows(matchers[global:], opts.Window)
+	if opts.Workers <= 1 || !ok {
+		return runMatchers(matchers, result, system, bank, opts)
+	}
+
+	system, bank = runMatchers(matchers[:global], result, system, bank, opts)
+	blocks := dayBlocks(system, bank, windows)
+	if len(blocks) <= 1 {
+		return runMatchers(matchers[global:], result, system, bank, opts)
+	}
+
+	jobs := make(chan *dayBlock)
+	var wg sync.WaitGroup
+	for w := 0; w < min(opts.Workers, len(blocks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for block := range jobs {
+				block.system, block.bank = runMatchers(matchers[global:], &block.result, block.system, block.bank, opts)
+			}
+		}()
+	}
+	for i := range blocks {
+		jobs <- &blocks[i]
+	}
+	close(jobs)
+	wg.Wait()
+
+	var leftSystem []SystemTransaction
+	var leftBank []BankTransaction
+	for _, block := range blocks {
+		result.Matched = append(result.Matched, block.result.Matched...)
+		result.GroupMatches = append(result.GroupMatches, block.result.GroupMatches...)
+		leftSystem = append(leftSystem, block.system...)
+		leftBank = append(leftBank, block.bank...)
+	}
+
+	return inInputOrder(system, leftSystem), inInputOrder(bank, leftBank)
+}
+
+// matcherWindows returns the settlement windows bounding the pipeline
+// matches. It reports false when a stage is not known to be bounded by one.
+func matcherWindows(matchers []Matcher, window SettlementWindow) ([]SettlementWindow, bool) {
+	windows := []SettlementWindow{window}
+	for _, m := range matchers {
+		switch m := m.(type) {
+		case exactKeyMatcher, splitMatcher, feeMatcher, discrepancyMatcher, fxMatcher:
+		case ruleMatcher:
+			windows = append(windows, m.rule.Window)
+		default:
+			return nil, false
+		}
+	}
+	return windows, true
+}
+
+// dayBlocks groups the transactions into blocks of consecutive days, closing
+// a block once no system transaction in it can settle on the next day. The
+// blocks are in day order and keep the input order of their transactions.
+func dayBlocks(system []SystemTransaction, bank []BankTransaction, windows []SettlementWindow) []dayBlock {
+	// latest bank day each day can be matched against
+	reach := make(map[string]string)
+	for _, sys := range system {
+		day := dayOf(sys.TransactionTime)
+		key := day.Format(BankTimeFormat)
+		for _, w := range windows {
+			if last := w.lastDate(day).Format(BankTimeFormat); last > reach[key] {
+				reach[key] = last
+			}
+		}
+	}
+	for _, b := range bank {
+		if key := b.Date.Format(BankTimeFormat); key > reach[key] {
+			reach[key] = key
+		}
+	}
+
+	days := make([]string, 0, len(reach))
+	for day := range reach {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	blockOf := make(map[string]int, len(days))
+	block, end := -1, ""
+	for _, day := range days {
+		if block < 0 || day > end {
+			block++
+			end = reach[day]
+		} else if reach[day] > end {
+			end = reach[day]
+		}
+		blockOf[day] = block
+	}
+
+	blocks := make([]dayBlock, block+1)
+	for _, sys := range system {
+		i := blockOf[dayOf(sys.TransactionTime).Format(BankTimeFormat)]
+		blocks[i].system = append(blocks[i].system, sys)
+	}
+	for _, b := range bank {
+		i := blockOf[b.Date.Format(BankTimeFormat)]
+		blocks[i].bank = append(blocks[i].bank, b)
+	}
+	return blocks
+}
+
+// inInputOrder returns the items of left in the order they appear in all.
+func inInputOrder[T comparable](all, left []T) (out []T) {
+	count := make(map[T]int, len(left))
+	for _, item := range left {
+		count[item]++
+	}
+	for _, item := range all {
+		if count[item] > 0 {
+			count[item]--
+			out = append(out, item)
+		}
+	}
+	return out
+}
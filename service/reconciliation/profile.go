@@ -0,0 +1,432 @@
+package reconciliation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultProfileName is the name of the built-in profiles describing the
+	// legacy positional layouts.
+	DefaultProfileName = "default"
+	// AutoProfileName selects the bank profile of an upload from its file
+	// name and header row.
+	AutoProfileName = "auto"
+)
+
+// AmountSign tells how a bank profile represents debits and credits.
+type AmountSign string
+
+const (
+	// SignedAmount is a single amount column, negative for debits.
+	SignedAmount AmountSign = "signed"
+	// DebitCreditColumns are separate unsigned debit and credit columns.
+	DebitCreditColumns AmountSign = "debit_credit_columns"
+	// DrCrFlag is an unsigned amount column plus a debit/credit flag column.
+	DrCrFlag AmountSign = "dr_cr_flag"
+)
+
+// BankColumns maps BankTransaction fields to statement columns. A column is
+// referenced by header name, matched case-insensitively, or by zero-based
+// index.
+type BankColumns struct {
+	UniqueID    string
+	Amount      string
+	Debit       string
+	Credit      string
+	DrCr        string
+	Date        string
+	Description string
+	Currency    string
+}
+
+// BankProfile describes the layout of one bank's statement export. BankName
+// is reported on every transaction loaded with the profile; when empty the
+// uploaded file name is used. FilenamePattern is an optional glob, such as
+// BCA_Statement*, preferring the profile for uploads whose name matches.
+// Currency applies to rows without a currency column value. Location is the
+// time zone of the statement dates, UTC when nil.
+type BankProfile struct {
+	Name               string
+	BankName           string
+	FilenamePattern    string
+	Currency           string
+	Delimiter          string
+	Columns            BankColumns
+	DateLayout         string
+	DecimalSeparator   string
+	ThousandsSeparator string
+	Rounding           RoundingMode
+	AmountSign         AmountSign
+	DebitFlag          string
+	CreditFlag         string
+	Location           *time.Location
+}
+
+// SystemColumns maps SystemTransaction fields to system export columns, by
+// header name or zero-based index.
+type SystemColumns struct {
+	TransactionID   string
+	Amount          string
+	Type            string
+	TransactionTime string
+	Currency        string
+}
+
+// SystemProfile describes the layout of a system transaction export.
+// Currency applies to rows without a currency column value. Location is the
+// time zone of timestamps written without offset, UTC when nil. Times are
+// parsed with TimeLayout then TimeLayouts, in order; Go time layouts,
+// UnixLayout and UnixMilliLayout are accepted.
+type SystemProfile struct {
+	Name               string
+	Currency           string
+	Delimiter          string
+	Columns            SystemColumns
+	TimeLayout         string
+	TimeLayouts        []string
+	DecimalSeparator   string
+	ThousandsSeparator string
+	Rounding           RoundingMode
+	Location           *time.Location
+}
+
+// DefaultBankProfile is the legacy unique_identifier,amount,date layout.
+var DefaultBankProfile = BankProfile{
+	Name:       DefaultProfileName,
+	Delimiter:  ",",
+	Columns:    BankColumns{UniqueID: "0", Amount: "1", Date: "2"},
+	DateLayout: BankTimeFormat,
+	AmountSign: SignedAmount,
+}
+
+// DefaultSystemProfile is the legacy trxID,amount,type,transactionTime layout.
+var DefaultSystemProfile = SystemProfile{
+	Name:        DefaultProfileName,
+	Delimiter:   ",",
+	Columns:     SystemColumns{TransactionID: "0", Amount: "1", Type: "2", TransactionTime: "3"},
+	TimeLayouts: DefaultSystemTimeLayouts,
+}
+
+// withDefaults fills the optional settings of the profile.
+func (p BankProfile) withDefaults() BankProfile {
+	if p.Delimiter == "" {
+		p.Delimiter = ","
+	}
+	if p.DateLayout == "" {
+		p.DateLayout = BankTimeFormat
+	}
+	if p.DecimalSeparator == "" {
+		p.DecimalSeparator = "."
+	}
+	if p.AmountSign == "" {
+		p.AmountSign = SignedAmount
+	}
+	if p.DebitFlag == "" {
+		p.DebitFlag = "DR"
+	}
+	if p.CreditFlag == "" {
+		p.CreditFlag = "CR"
+	}
+	return p
+}
+
+// Validate checks the profile describes a usable layout.
+func (p BankProfile) Validate() error {
+	p = p.withDefaults()
+
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("bank profile name is required")
+	}
+	if err := validateSeparators(p.Delimiter, p.DecimalSeparator, p.ThousandsSeparator); err != nil {
+		return fmt.Errorf("bank profile %q: %v", p.Name, err)
+	}
+	if err := p.Rounding.Validate(); err != nil {
+		return fmt.Errorf("bank profile %q: %v", p.Name, err)
+	}
+	if p.Currency != "" && !IsCurrencyCode(normalizeCurrency(p.Currency)) {
+		return fmt.Errorf("bank profile %q: invalid currency %q (expected ISO 4217 code)", p.Name, p.Currency)
+	}
+	if _, err := path.Match(p.FilenamePattern, ""); err != nil {
+		return fmt.Errorf("bank profile %q: invalid filename pattern %q", p.Name, p.FilenamePattern)
+	}
+	if p.Columns.UniqueID == "" || p.Columns.Date == "" {
+		return fmt.Errorf("bank profile %q: unique_id and date columns are required", p.Name)
+	}
+
+	switch p.AmountSign {
+	case SignedAmount:
+		if p.Columns.Amount == "" {
+			return fmt.Errorf("bank profile %q: amount column is required", p.Name)
+		}
+	case DebitCreditColumns:
+		if p.Columns.Debit == "" || p.Columns.Credit == "" {
+			return fmt.Errorf("bank profile %q: debit and credit columns are required", p.Name)
+		}
+	case DrCrFlag:
+		if p.Columns.Amount == "" || p.Columns.DrCr == "" {
+			return fmt.Errorf("bank profile %q: amount and dr_cr columns are required", p.Name)
+		}
+	default:
+		return fmt.Errorf("bank profile %q: unknown amount sign %q", p.Name, p.AmountSign)
+	}
+	return nil
+}
+
+// withDefaults fills the optional settings of the profile.
+func (p SystemProfile) withDefaults() SystemProfile {
+	if p.Delimiter == "" {
+		p.Delimiter = ","
+	}
+	if p.TimeLayout == "" && len(p.TimeLayouts) == 0 {
+		p.TimeLayouts = DefaultSystemTimeLayouts
+	}
+	if p.DecimalSeparator == "" {
+		p.DecimalSeparator = "."
+	}
+	return p
+}
+
+// Validate checks the profile describes a usable layout.
+func (p SystemProfile) Validate() error {
+	p = p.withDefaults()
+
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("system profile name is required")
+	}
+	if err := validateSeparators(p.Delimiter, p.DecimalSeparator, p.ThousandsSeparator); err != nil {
+		return fmt.Errorf("system profile %q: %v", p.Name, err)
+	}
+	if err := p.Rounding.Validate(); err != nil {
+		return fmt.Errorf("system profile %q: %v", p.Name, err)
+	}
+	if p.Currency != "" && !IsCurrencyCode(normalizeCurrency(p.Currency)) {
+		return fmt.Errorf("system profile %q: invalid currency %q (expected ISO 4217 code)", p.Name, p.Currency)
+	}
+	for _, layout := range p.TimeLayouts {
+		if strings.TrimSpace(layout) == "" {
+			return fmt.Errorf("system profile %q: empty time layout", p.Name)
+		}
+	}
+	c := p.Columns
+	if c.TransactionID == "" || c.Amount == "" || c.Type == "" || c.TransactionTime == "" {
+		return fmt.Errorf("system profile %q: transaction_id, amount, type and transaction_time columns are required", p.Name)
+	}
+	return nil
+}
+
+// timeLayouts returns the layouts tried in order for transaction times.
+func (p SystemProfile) timeLayouts() []string {
+	if p.TimeLayout == "" {
+		return p.TimeLayouts
+	}
+	return append([]string{p.TimeLayout}, p.TimeLayouts...)
+}
+
+func (p BankProfile) amountFormat() AmountFormat {
+	return AmountFormat{DecimalSeparator: p.DecimalSeparator, ThousandsSeparator: p.ThousandsSeparator, Rounding: p.Rounding}
+}
+
+func (p SystemProfile) amountFormat() AmountFormat {
+	return AmountFormat{DecimalSeparator: p.DecimalSeparator, ThousandsSeparator: p.ThousandsSeparator, Rounding: p.Rounding}
+}
+
+func validateSeparators(delimiter, decimal, thousands string) error {
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	if utf8.RuneCountInString(decimal) != 1 {
+		return fmt.Errorf("decimal separator must be a single character")
+	}
+	if thousands != "" && (utf8.RuneCountInString(thousands) != 1 || thousands == decimal) {
+		return fmt.Errorf("thousands separator must be a single character different from the decimal separator")
+	}
+	return nil
+}
+
+// WithBankProfiles registers bank statement layouts selectable by name and
+// considered, in order, when detecting the layout of an upload. A profile
+// replaces the registered profile of the same name. Profiles are expected to
+// pass Validate.
+func WithBankProfiles(profiles ...BankProfile) Option {
+	return func(s *reconciliationService) {
+	next:
+		for _, p := range profiles {
+			for i := range s.bankProfiles {
+				if s.bankProfiles[i].Name == p.Name {
+					s.bankProfiles[i] = p.withDefaults()
+					continue next
+				}
+			}
+			s.bankProfiles = append(s.bankProfiles, p.withDefaults())
+		}
+	}
+}
+
+// WithSystemProfiles registers system export layouts selectable by name.
+// Profiles are expected to pass Validate.
+func WithSystemProfiles(profiles ...SystemProfile) Option {
+	return func(s *reconciliationService) {
+		for _, p := range profiles {
+			s.systemProfiles[p.Name] = p.withDefaults()
+		}
+	}
+}
+
+// bankProfile returns the named bank profile, the default one when name is
+// empty.
+func (s *reconciliationService) bankProfile(name string) (BankProfile, error) {
+	if name == "" {
+		name = DefaultProfileName
+	}
+	for _, p := range s.bankProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return BankProfile{}, fmt.Errorf("unknown bank profile %q", name)
+}
+
+// systemProfile returns the named system profile, the default one when name
+// is empty.
+func (s *reconciliationService) systemProfile(name string) (SystemProfile, error) {
+	if name == "" {
+		name = DefaultProfileName
+	}
+	p, ok := s.systemProfiles[name]
+	if !ok {
+		return SystemProfile{}, fmt.Errorf("unknown system profile %q", name)
+	}
+	return p, nil
+}
+
+// detectBankProfile picks the bank profile of an upload. A profile fits when
+// all of its columns resolve against the header row, headerLine split with
+// the profile delimiter or, for spreadsheets, the cells. Among the fitting
+// profiles, those whose filename pattern matches come first, then those
+// naming the most columns by header, then the registration order. The
+// positional default profile fits only the legacy header, so that an unknown
+// layout is rejected rather than misread.
+func (s *reconciliationService) detectBankProfile(filename, headerLine string, cells []string) (BankProfile, error) {
+	var (
+		best      BankProfile
+		bestNamed int
+		bestMatch bool
+		found     bool
+	)
+
+	for _, p := range s.bankProfiles {
+		header := cells
+		if header == nil {
+			var err error
+			if header, err = newCSVReader(strings.NewReader(headerLine), p.Delimiter).Read(); err != nil {
+				continue
+			}
+		}
+		named, ok := p.fits(header)
+		if !ok || p.Name == DefaultProfileName && named == 0 && !isLegacyBankHeader(header) {
+			continue
+		}
+		match := p.matchesFilename(filename)
+		if found && (bestMatch && !match || bestMatch == match && named <= bestNamed) {
+			continue
+		}
+		best, bestNamed, bestMatch, found = p, named, match, true
+	}
+
+	if !found {
+		return BankProfile{}, fmt.Errorf("no bank profile fits header %q", strings.TrimSpace(headerLine))
+	}
+	return best, nil
+}
+
+// filenameBankProfile returns the first bank profile whose filename pattern
+// matches filename, or no profile.
+func (s *reconciliationService) filenameBankProfile(filename string) BankProfile {
+	for _, p := range s.bankProfiles {
+		if p.matchesFilename(filename) {
+			return p
+		}
+	}
+	return BankProfile{}
+}
+
+// fits tells whether every column of the profile resolves against the header
+// row, and how many of them are resolved by name.
+func (p BankProfile) fits(header []string) (named int, ok bool) {
+	for _, ref := range []string{p.Columns.UniqueID, p.Columns.Amount, p.Columns.Debit, p.Columns.Credit, p.Columns.DrCr, p.Columns.Date, p.Columns.Description, p.Columns.Currency} {
+		if ref == "" {
+			continue
+		}
+		idx, err := columnIndex(header, ref)
+		if err != nil {
+			return 0, false
+		}
+		if strings.EqualFold(headerName(header[idx]), strings.TrimSpace(ref)) {
+			named++
+		}
+	}
+	return named, true
+}
+
+// isLegacyBankHeader tells whether header starts with the columns of the
+// legacy bank export.
+func isLegacyBankHeader(header []string) bool {
+	if len(header) < 3 {
+		return false
+	}
+	id := strings.ToLower(headerName(header[0]))
+	return (id == "unique_identifier" || id == "unique_id") &&
+		strings.EqualFold(headerName(header[1]), "amount") &&
+		strings.EqualFold(headerName(header[2]), "date")
+}
+
+func (p BankProfile) matchesFilename(filename string) bool {
+	if p.FilenamePattern == "" {
+		return false
+	}
+	ok, _ := path.Match(strings.ToLower(p.FilenamePattern), strings.ToLower(filename))
+	return ok
+}
+
+// bankProfileName returns the profile requested for the i-th uploaded file,
+// empty when none is.
+func (o ReconcileOptions) bankProfileName(i int) string {
+	switch {
+	case len(o.BankProfiles) == 1:
+		return o.BankProfiles[0]
+	case i < len(o.BankProfiles):
+		return o.BankProfiles[i]
+	}
+	return ""
+}
+
+// sniffHeader reads the header line of r, the first one that is not blank,
+// without the byte order mark. The returned reader yields the whole content
+// again from the header on, with the BOM dropped and the blank lines before
+// it kept so line numbers do not shift.
+func sniffHeader(r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReader(r)
+	blank := ""
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", nil, err
+		}
+		if blank == "" {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) != "" {
+			return line, io.MultiReader(strings.NewReader(blank+line), br), nil
+		}
+		if err == io.EOF {
+			return "", nil, fmt.Errorf("missing header row")
+		}
+		blank += "\n"
+	}
+}
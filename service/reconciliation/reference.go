@@ -0,0 +1,88 @@
+package reconciliation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReferenceRule extracts our transaction ID from a bank statement column so
+// bank lines can be matched by reference. Column is the header name of the
+// column to read. Pattern is an optional regular expression: the first capture
+// group is used as the reference when present, otherwise the whole match. An
+// empty Pattern uses the trimmed column value as is.
+type ReferenceRule struct {
+	Column  string
+	Pattern string
+}
+
+func (r ReferenceRule) IsZero() bool {
+	return r.Column == ""
+}
+
+// Validate checks the rule pattern compiles.
+func (r ReferenceRule) Validate() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid reference pattern %q: %v", r.Pattern, err)
+	}
+	return nil
+}
+
+// referenceExtractor applies a compiled ReferenceRule to statement rows.
+type referenceExtractor struct {
+	column  int
+	pattern *regexp.Regexp
+}
+
+// newReferenceExtractor resolves the rule column against the header row. It
+// returns nil when the rule is empty or the column is not present.
+func newReferenceExtractor(rule ReferenceRule, header []string) (*referenceExtractor, error) {
+	if rule.IsZero() {
+		return nil, nil
+	}
+
+	ex := &referenceExtractor{column: -1}
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), rule.Column) {
+			ex.column = i
+			break
+		}
+	}
+	if ex.column < 0 {
+		return nil, nil
+	}
+
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference pattern %q: %v", rule.Pattern, err)
+		}
+		ex.pattern = pattern
+	}
+	return ex, nil
+}
+
+// extract returns the raw column value and the reference found in it.
+func (ex *referenceExtractor) extract(record []string) (description string, reference string) {
+	if ex == nil || ex.column >= len(record) {
+		return "", ""
+	}
+
+	description = strings.TrimSpace(record[ex.column])
+	if ex.pattern == nil {
+		return description, description
+	}
+
+	match := ex.pattern.FindStringSubmatch(description)
+	switch {
+	case match == nil:
+		return description, ""
+	case len(match) > 1:
+		return description, match[1]
+	default:
+		return description, match[0]
+	}
+}
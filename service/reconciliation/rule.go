@@ -0,0 +1,103 @@
+package reconciliation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule is a declarative matching rule such as "same date, amount within 0.5%,
+// bank = BCA". It pairs the remaining system transactions with the first bank
+// line, closest date first, posted within Window whose amount is within
+// Tolerance, or equal to the system amount when Tolerance is zero. An empty
+// Bank applies the rule to every bank.
+type Rule struct {
+	Name      string
+	Bank      string
+	Window    SettlementWindow
+	Tolerance Tolerance
+}
+
+// ValidateRules checks a rule set before it is used by the service.
+func ValidateRules(rules []Rule) error {
+	names := map[string]bool{}
+	for _, m := range DefaultMatchers() {
+		names[m.Name()] = true
+	}
+
+	for i, rule := range rules {
+		switch {
+		case strings.TrimSpace(rule.Name) == "":
+			return fmt.Errorf("rule #%d: name is required", i+1)
+		case names[rule.Name]:
+			return fmt.Errorf("rule %q: duplicate or reserved name", rule.Name)
+		case rule.Window.MaxDays < 0:
+			return fmt.Errorf("rule %q: settlement days must not be negative", rule.Name)
+		case rule.Window.MaxDays > MaxSettlementDays:
+			return fmt.Errorf("rule %q: settlement days must not exceed %d", rule.Name, MaxSettlementDays)
+		case rule.Tolerance.Amount < 0 || rule.Tolerance.Percent < 0:
+			return fmt.Errorf("rule %q: tolerance must not be negative", rule.Name)
+		case rule.Tolerance.Percent > 100:
+			return fmt.Errorf("rule %q: tolerance percent must not exceed 100", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// WithRules adds declarative rules to the matching pipeline. They run in
+// order just before the discrepancy stage, or last when the pipeline has no
+// discrepancy stage. Rules are expected to pass ValidateRules.
+func WithRules(rules ...Rule) Option {
+	return func(s *reconciliationService) {
+		s.rules = append(s.rules, rules...)
+	}
+}
+
+type ruleMatcher struct {
+	rule Rule
+}
+
+// RuleMatcher returns the matcher for a declarative rule.
+func RuleMatcher(rule Rule) Matcher {
+	return ruleMatcher{rule: rule}
+}
+
+func (m ruleMatcher) Name() string {
+	return m.rule.Name
+}
+
+func (m ruleMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) []Match {
+	return firstFit(system, bank, m.rule.Window, func(sys SystemTransaction, b BankTransaction) bool {
+		if m.rule.Bank != "" && !strings.EqualFold(m.rule.Bank, b.BankName) {
+			return false
+		}
+		return b.Currency == sys.Currency && m.rule.Tolerance.bounds(sys.Currency, getSignedAmount(sys), b.Amount)
+	})
+}
+
+// withRuleMatchers inserts the rule matchers into the pipeline before the
+// discrepancy stage.
+func withRuleMatchers(matchers []Matcher, rules []Rule) []Matcher {
+	if len(rules) == 0 {
+		return matchers
+	}
+
+	var ruleMatchers []Matcher
+	for _, rule := range rules {
+		ruleMatchers = append(ruleMatchers, RuleMatcher(rule))
+	}
+
+	pipeline := make([]Matcher, 0, len(matchers)+len(ruleMatchers))
+	inserted := false
+	for _, m := range matchers {
+		if _, ok := m.(discrepancyMatcher); ok && !inserted {
+			pipeline = append(pipeline, ruleMatchers...)
+			inserted = true
+		}
+		pipeline = append(pipeline, m)
+	}
+	if !inserted {
+		pipeline = append(pipeline, ruleMatchers...)
+	}
+	return pipeline
+}
@@ -1,11 +1,11 @@
 package reconciliation
 
 import (
-	"encoding/csv"
+	"bufio"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 )
@@ -16,219 +16,552 @@ const (
 )
 
 type reconciliationService struct {
+	matchers       []Matcher
+	rules          []Rule
+	bankProfiles   []BankProfile
+	systemProfiles map[string]SystemProfile
+	fxRates        *FXRates
+	feeRules       []FeeRule
+	timezones      Timezones
+	workers        int
 }
 
-type ReconciliationService interface {
-	Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []SystemTransaction, bankTransactions []BankTransaction) (ReconciliationResult, error)
-}
+// Option configures the reconciliation service.
+type Option func(*reconciliationService)
 
-func NewReconciliationService() ReconciliationService {
-	return &reconciliationService{}
+// WithMatchers replaces the matching pipeline with the given matchers, run in
+// order. Use DefaultMatchers to extend the built-in pipeline with custom
+// matchers.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(s *reconciliationService) {
+		s.matchers = matchers
+	}
 }
 
-func (s *reconciliationService) Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []SystemTransaction, bankTransactions []BankTransaction) (res ReconciliationResult, err error) {
+type ReconciliationService interface {
+	Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []SystemTransaction, bankTransactions []BankTransaction, opts ReconcileOptions) (ReconciliationResult, error)
+	Rules() []Rule
+	NewStream(startDate string, endDate string, opts ReconcileOptions) (*Stream, error)
+}
 
-	startTime, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		return ReconciliationResult{}, fmt.Errorf("invalid start_date (expected YYYY-MM-DD)")
+func NewReconciliationService(opts ...Option) ReconciliationService {
+	s := &reconciliationService{
+		matchers:       DefaultMatchers(),
+		bankProfiles:   []BankProfile{DefaultBankProfile.withDefaults()},
+		systemProfiles: map[string]SystemProfile{DefaultProfileName: DefaultSystemProfile.withDefaults()},
+		timezones:      DefaultTimezones(),
+		workers:        1,
 	}
-	endTime, err := time.Parse("2006-01-02", endDate)
-	if err != nil {
-		return ReconciliationResult{}, fmt.Errorf("invalid end_date (expected YYYY-MM-DD)")
+
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.matchers = withRuleMatchers(s.matchers, s.rules)
+
+	return s
+}
+
+// Rules returns the active declarative matching rules.
+func (s *reconciliationService) Rules() []Rule {
+	return s.rules
+}
 
-	endTime = endTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+// Reconcile reconciles the system export sysData with the bank statements
+// uploaded in attachement, and with the transactions given directly, which
+// are filtered by the period like the rows of files. sysData and attachement
+// may be nil when all transactions are given.
+func (s *reconciliationService) Reconcile(startDate string, endDate string, sysData io.Reader, attachement *multipart.Form, systemTransactions []SystemTransaction, bankTransactions []BankTransaction, opts ReconcileOptions) (res ReconciliationResult, err error) {
 
-	sysTrx, err := LoadSystemTransactions(sysData, startTime, endTime)
+	req, err := s.newRequest(startDate, endDate, opts)
 	if err != nil {
-		return ReconciliationResult{}, fmt.Errorf("failed to load system transactions: %v", err)
+		return ReconciliationResult{}, err
+	}
+	opts = req.opts
+	if attachement == nil {
+		attachement = &multipart.Form{}
 	}
 
-	var allBankTrx []BankTransaction
+	sysTrx, allBankTrx := req.inPeriod(systemTransactions, bankTransactions)
 
-	for _, fileHeader := range attachement.File["bank_csv"] {
-		f, err := fileHeader.Open()
+	var parseErrors []ParseError
+	if sysData != nil {
+		parseErrors, err = readSystemData(sysData, req, func(trx SystemTransaction) error {
+			sysTrx = append(sysTrx, trx)
+			return nil
+		})
 		if err != nil {
-			continue
-		}
-		defer f.Close()
-
-		bankName := fmt.Sprintf("Stmt-%s", fileHeader.Filename)
-		bTrx, err := LoadBankStatement(f, bankName, startTime, endTime)
-		if err == nil {
-			allBankTrx = append(allBankTrx, bTrx...)
+			return ReconciliationResult{}, fmt.Errorf("failed to load system transactions: %v", err)
 		}
 	}
 
-	return reconcileProcess(sysTrx, allBankTrx), nil
-}
+	var files []FileReport
+	for i, fileHeader := range attachement.File["bank_csv"] {
+		report, bTrx, bErrs := s.loadBankFile(fileHeader, i, req)
+		allBankTrx = append(allBankTrx, bTrx...)
+		files = append(files, report)
+		parseErrors = append(parseErrors, bErrs...)
+	}
 
-func reconcileProcess(systemTransactions []SystemTransaction, bankTransactions []BankTransaction) (result ReconciliationResult) {
-	result = ReconciliationResult{
-		UnmatchedBank:  make(map[string][]BankTransaction),
-		TotalProcessed: len(systemTransactions) + len(bankTransactions),
+	if opts.FXRates, err = s.requestFXRates(attachement, opts.FXRates); err != nil {
+		return ReconciliationResult{}, err
 	}
-	bankMap := make(map[string][]int)
-	matchedBanks := make(map[int]bool)
 
-	for i, b := range bankTransactions {
-		key := generateKey(b.Date, b.Amount)
-		bankMap[key] = append(bankMap[key], i)
+	if opts.Strict && len(parseErrors) > 0 {
+		return ReconciliationResult{}, &StrictModeError{Errors: parseErrors}
 	}
-	var stillUnmatchedSystem []SystemTransaction
 
-	for _, sys := range systemTransactions {
-		sysDate := time.Date(sys.TransactionTime.Year(), sys.TransactionTime.Month(), sys.TransactionTime.Day(), 0, 0, 0, 0, time.UTC)
-		finalAmount := getSignedAmount(sys)
+	result := reconcileWith(s.matchers, sysTrx, allBankTrx, opts)
+	result.Files = files
+	result.ParseErrors = parseErrors
 
-		key := generateKey(sysDate, finalAmount)
+	return result, nil
+}
+
+// request holds the settings of one reconciliation resolved against the
+// service configuration. System transactions are loaded between start and
+// end, bank lines up to bankEnd since they may settle after end_date.
+type request struct {
+	start, end, bankEnd time.Time
+	system              SystemProfile
+	opts                ReconcileOptions
+}
 
-		indices, exists := bankMap[key]
-		matched := false
+// newRequest validates the request period and profiles and completes opts
+// with the service settings.
+func (s *reconciliationService) newRequest(startDate, endDate string, opts ReconcileOptions) (req request, err error) {
+	req.start, err = time.ParseInLocation("2006-01-02", startDate, s.timezones.Filter)
+	if err != nil {
+		return req, fmt.Errorf("invalid start_date (expected YYYY-MM-DD)")
+	}
+	req.end, err = time.ParseInLocation("2006-01-02", endDate, s.timezones.Filter)
+	if err != nil {
+		return req, fmt.Errorf("invalid end_date (expected YYYY-MM-DD)")
+	}
 
-		if exists {
-			for _, idx := range indices {
-				if !matchedBanks[idx] {
-					matchedBanks[idx] = true
-					matched = true
-					result.TotalMatched++
+	req.end = req.end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
 
-					break
-				}
+	if err = opts.Window.validate(); err != nil {
+		return req, err
+	}
+	if opts.MaxGroupSize < 0 || opts.MaxGroupSize > MaxSplitGroupSize {
+		return req, fmt.Errorf("invalid max_group_size (expected integer between 0 and %d)", MaxSplitGroupSize)
+	}
+	if req.system, err = s.systemProfile(opts.SystemProfile); err != nil {
+		return req, err
+	}
+	formats := make([]BankFormat, len(opts.BankFormats))
+	for i, format := range opts.BankFormats {
+		if formats[i], err = ParseBankFormat(string(format)); err != nil {
+			return req, err
+		}
+	}
+	opts.BankFormats = formats
+	for _, name := range opts.BankProfiles {
+		if name != "" && name != AutoProfileName {
+			if _, err = s.bankProfile(name); err != nil {
+				return req, err
 			}
 		}
+	}
 
-		if !matched {
-			stillUnmatchedSystem = append(stillUnmatchedSystem, sys)
-		}
+	if req.system.Currency == "" {
+		req.system.Currency = opts.Currency
+	}
+	if req.system.Location == nil {
+		req.system.Location = s.timezones.System
 	}
 
-	bankMapByDate := make(map[string][]int)
-	for i, b := range bankTransactions {
-		if !matchedBanks[i] {
-			dKey := b.Date.Format("2006-01-02")
-			bankMapByDate[dKey] = append(bankMapByDate[dKey], i)
+	// bank lines for the last days may settle after end_date
+	req.bankEnd = opts.Window.lastDate(dayOf(req.end))
+	for _, rule := range s.rules {
+		if last := rule.Window.lastDate(dayOf(req.end)); last.After(req.bankEnd) {
+			req.bankEnd = last
 		}
 	}
+	req.bankEnd = req.bankEnd.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
 
-	for _, sys := range stillUnmatchedSystem {
-		sysDateKey := sys.TransactionTime.Format("2006-01-02")
-		foundDiscrepancy := false
+	opts.FeeRules = append(opts.FeeRules, s.feeRules...)
+	opts.Timezone = s.timezones.Filter
+	if opts.Workers == 0 {
+		opts.Workers = s.workers
+	}
+	req.opts = opts
 
-		if indices, exists := bankMapByDate[sysDateKey]; exists {
-			for _, idx := range indices {
-				if !matchedBanks[idx] {
-					bankTrx := bankTransactions[idx]
-					sysSignedAmount := getSignedAmount(sys)
+	return req, nil
+}
 
-					diff := sysSignedAmount - bankTrx.Amount
-					if diff < 0 {
-						diff = -diff
-					}
+// inPeriod returns the system transactions of the request period and the
+// bank transactions posted up to the bank end date, in order.
+func (req request) inPeriod(system []SystemTransaction, bank []BankTransaction) ([]SystemTransaction, []BankTransaction) {
+	var sysOut []SystemTransaction
+	for _, sys := range system {
+		if !sys.TransactionTime.Before(req.start) && !sys.TransactionTime.After(req.end) {
+			sysOut = append(sysOut, sys)
+		}
+	}
+	var bankOut []BankTransaction
+	for _, b := range bank {
+		if day := dayOf(b.Date); !day.Before(dayOf(req.start)) && !day.After(dayOf(req.bankEnd)) {
+			bankOut = append(bankOut, b)
+		}
+	}
+	return sysOut, bankOut
+}
 
-					fmt.Printf("[Discrepancy] Date: %s | SysID: %s | Diff: %.2f",
-						sysDateKey, sys.TransactionID, diff.ToFloat())
+// readSystemData reads the system export of a request, a CSV file or an XLSX
+// workbook, passing its transactions to emit.
+func readSystemData(r io.Reader, req request, emit func(SystemTransaction) error) ([]ParseError, error) {
+	profile := req.system.withDefaults()
 
-					result.TotalDiscrepancies += diff
-					result.TotalMatched++
-					matchedBanks[idx] = true
-					foundDiscrepancy = true
-					break
-				}
-			}
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(xlsxSignature)); string(head) != xlsxSignature {
+		return loadSystemRecords(newCSVReader(br, profile.Delimiter), SystemDataFile, profile, req.start, req.end, emit)
+	}
+
+	sheet, err := newXLSXReader(br, req.opts.SheetName, req.opts.HeaderRow)
+	if err != nil {
+		return nil, err
+	}
+	sheet.DecimalSeparator = profile.DecimalSeparator
+	for _, layout := range profile.timeLayouts() {
+		if layout != UnixLayout && layout != UnixMilliLayout {
+			sheet.DateLayout = layout
+			break
 		}
+	}
+	return loadSystemRecords(sheet, SystemDataFile, profile, req.start, req.end, emit)
+}
+
+// loadBankFile loads the n-th uploaded bank file with the format and profile
+// requested for it, detecting those left to auto from the file. A file that
+// cannot be read at all is reported as a single parse error.
+func (s *reconciliationService) loadBankFile(fileHeader *multipart.FileHeader, n int, req request) (report FileReport, trxs []BankTransaction, parseErrors []ParseError) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		reason := fmt.Sprintf("failed to open file: %v", err)
+		return FileReport{FileName: fileHeader.Filename, Error: reason}, nil, []ParseError{{File: fileHeader.Filename, Reason: reason}}
+	}
+	defer f.Close()
 
-		if !foundDiscrepancy {
-			result.UnmatchedSystem = append(result.UnmatchedSystem, sys)
+	report, parseErrors, _ = s.readBankFile(fileHeader.Filename, f, n, req, func(trx BankTransaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+	return report, trxs, parseErrors
+}
+
+// readBankFile reads a bank file like loadBankFile, passing its lines to
+// emit. Only an emit failure is returned as error.
+func (s *reconciliationService) readBankFile(filename string, f io.Reader, n int, req request, emit func(BankTransaction) error) (report FileReport, parseErrors []ParseError, err error) {
+	report.FileName = filename
+
+	fail := func(reason string) (FileReport, []ParseError, error) {
+		report.Error = reason
+		return report, []ParseError{{File: filename, Reason: reason}}, nil
+	}
+
+	headerLine, r, err := sniffHeader(f)
+	if err != nil {
+		return fail(fmt.Sprintf("failed to read header: %v", err))
+	}
+
+	report.Format = req.opts.bankFormat(n)
+	if report.Format == BankFormatAuto {
+		report.Format, r = sniffBankFormat(r)
+	}
+
+	var (
+		sheet       *xlsxReader
+		headerCells []string
+	)
+	if report.Format == BankFormatXLSX {
+		if sheet, err = newXLSXReader(r, req.opts.SheetName, req.opts.HeaderRow); err != nil {
+			return fail(err.Error())
+		}
+		if headerCells = sheet.header(); headerCells == nil {
+			return fail("failed to read header: missing header row")
 		}
+		headerLine = strings.Join(headerCells, ",")
+	}
+
+	var profile BankProfile
+	switch profileName := req.opts.bankProfileName(n); {
+	case profileName != "" && profileName != AutoProfileName:
+		profile, err = s.bankProfile(profileName)
+	case report.Format != BankFormatCSV && report.Format != BankFormatXLSX:
+		// only the bank name, currency and time zone of a profile apply
+		profile = s.filenameBankProfile(filename)
+	case profileName == "" && len(s.bankProfiles) == 1:
+		// nothing to choose from: the legacy positional layout
+		profile = s.bankProfiles[0]
+	default:
+		profile, err = s.detectBankProfile(filename, headerLine, headerCells)
+	}
+	if err != nil {
+		return fail(err.Error())
 	}
 
-	for i, b := range bankTransactions {
-		if !matchedBanks[i] {
-			result.UnmatchedBank[b.BankName] = append(result.UnmatchedBank[b.BankName], b)
+	report.Profile = profile.Name
+	if profile.Currency == "" {
+		profile.Currency = req.opts.Currency
+	}
+	if profile.Location == nil {
+		profile.Location = s.timezones.Bank
+	}
+	report.BankName = fmt.Sprintf("Stmt-%s", filename)
+	if profile.BankName != "" {
+		report.BankName = profile.BankName
+	}
+
+	var emitErr error
+	counted := func(trx BankTransaction) error {
+		if emitErr = emit(trx); emitErr != nil {
+			return emitErr
 		}
+		report.Transactions++
+		return nil
 	}
 
-	result.TotalUnmatched = len(result.UnmatchedSystem)
-	for _, v := range result.UnmatchedBank {
-		result.TotalUnmatched += len(v)
+	profile = profile.withDefaults()
+	rule := req.opts.referenceFor(report.BankName)
+	switch report.Format {
+	case BankFormatMT940:
+		report.Statements, parseErrors, err = loadMT940(r, filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	case BankFormatCAMT:
+		report.Statements, parseErrors, err = loadCAMT(r, filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	case BankFormatOFX:
+		report.Statements, parseErrors, err = loadOFX(r, filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	case BankFormatBAI2:
+		report.Statements, parseErrors, err = loadBAI2(r, filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	case BankFormatXLSX:
+		sheet.DateLayout, sheet.DecimalSeparator = profile.DateLayout, profile.DecimalSeparator
+		parseErrors, err = loadBankRecords(sheet, filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	default:
+		parseErrors, err = loadBankRecords(newCSVReader(r, profile.Delimiter), filename, profile, report.BankName, req.start, req.bankEnd, rule, counted)
+	}
+	if emitErr != nil {
+		return report, nil, emitErr
+	}
+	if err != nil {
+		return fail(err.Error())
 	}
 
-	return
+	return report, parseErrors, nil
 }
 
-func LoadSystemTransactions(r io.Reader, start, end time.Time) ([]SystemTransaction, error) {
-	csvReader := csv.NewReader(r)
+// reconcileProcess reconciles with the default matching pipeline.
+func reconcileProcess(systemTransactions []SystemTransaction, bankTransactions []BankTransaction, opts ReconcileOptions) ReconciliationResult {
+	return reconcileWith(DefaultMatchers(), systemTransactions, bankTransactions, opts)
+}
 
-	csvReader.TrimLeadingSpace = true
+func reconcileWith(matchers []Matcher, systemTransactions []SystemTransaction, bankTransactions []BankTransaction, opts ReconcileOptions) (result ReconciliationResult) {
+	result = ReconciliationResult{
+		UnmatchedBank:    make(map[string][]BankTransaction),
+		TotalProcessed:   len(systemTransactions) + len(bankTransactions),
+		Tolerance:        opts.Tolerance,
+		BankTolerances:   opts.BankTolerances,
+		SettlementWindow: opts.Window,
+		MaxGroupSize:     opts.MaxGroupSize,
+		MatchMode:        opts.Mode,
+		MinConfidence:    opts.MinConfidence,
+	}
+	systemTransactions, bankTransactions = withCurrency(systemTransactions, bankTransactions, opts.Currency)
+	if opts.Timezone != nil {
+		systemTransactions = inLocation(systemTransactions, opts.Timezone)
+	}
+	if opts.Mode == MatchModeOptimal {
+		// the outcome must not depend on CSV row order
+		systemTransactions, bankTransactions = sortedTransactions(systemTransactions, bankTransactions)
+	}
 
-	// Skip header
-	if _, err := csvReader.Read(); err != nil {
-		return nil, err
+	bankTransactions, result.Fees = separateFees(bankTransactions, opts.FeeRules)
+
+	unmatchedSystem, unmatchedBank := matchBlocks(matchers, &result, systemTransactions, bankTransactions, opts)
+
+	result.UnmatchedSystem = unmatchedSystem
+	for _, b := range unmatchedBank {
+		result.UnmatchedBank[b.BankName] = append(result.UnmatchedBank[b.BankName], b)
 	}
 
-	var trxs []SystemTransaction
+	finalizeMatches(&result, opts.MinConfidence)
 
-	for {
-		record, err := csvReader.Read()
-		if err == io.EOF {
-			break
+	result.TotalUnmatched = len(result.UnmatchedSystem)
+	for _, v := range result.UnmatchedBank {
+		result.TotalUnmatched += len(v)
+	}
+
+	result.Currencies = currencyTotals(result)
+	result.TotalDiscrepancies, result.TotalFees = overallTotals(result.Currencies)
+
+	return
+}
+
+// withCurrency returns the transactions with normalized currency codes,
+// defaulting to currency when a transaction has none.
+func withCurrency(system []SystemTransaction, bank []BankTransaction, currency string) ([]SystemTransaction, []BankTransaction) {
+	currency = normalizeCurrency(currency)
+
+	sysOut := make([]SystemTransaction, len(system))
+	for i, sys := range system {
+		if sys.Currency = normalizeCurrency(sys.Currency); sys.Currency == "" {
+			sys.Currency = currency
 		}
-		if err != nil {
-			continue
+		sysOut[i] = sys
+	}
+	bankOut := make([]BankTransaction, len(bank))
+	for i, b := range bank {
+		if b.Currency = normalizeCurrency(b.Currency); b.Currency == "" {
+			b.Currency = currency
 		}
+		bankOut[i] = b
+	}
+	return sysOut, bankOut
+}
 
-		tTime, _ := time.Parse(SystemTimeFormat, record[3])
-
-		if tTime.Before(start) || tTime.After(end) {
-			continue
+// currencyTotals breaks the result counters down per currency, sorted by
+// currency code.
+func currencyTotals(result ReconciliationResult) []CurrencyTotals {
+	byCurrency := make(map[string]*CurrencyTotals)
+	totals := func(currency string) *CurrencyTotals {
+		t, ok := byCurrency[currency]
+		if !ok {
+			t = &CurrencyTotals{Currency: currency}
+			byCurrency[currency] = t
 		}
+		return t
+	}
 
-		amountFloat, _ := strconv.ParseFloat(record[1], 64)
+	for _, pair := range result.Matched {
+		t := totals(pair.Bank.Currency)
+		t.TotalMatched++
+		t.TotalFees += pair.Fee
+		if pair.FX != nil {
+			t.TotalFXDifferences += absMoney(pair.FX.Difference)
+		}
+	}
+	for _, fee := range result.Fees {
+		totals(fee.Currency).TotalFees += absMoney(fee.Amount)
+	}
+	for _, group := range result.GroupMatches {
+		totals(group.Bank[0].Currency).TotalGroupMatched++
+	}
+	for _, d := range result.Discrepancies {
+		totals(d.Currency).TotalDiscrepancies += absMoney(d.Difference)
+	}
+	for _, sys := range result.UnmatchedSystem {
+		totals(sys.Currency).TotalUnmatched++
+	}
+	for _, lines := range result.UnmatchedBank {
+		for _, b := range lines {
+			totals(b.Currency).TotalUnmatched++
+		}
+	}
 
-		trxs = append(trxs, SystemTransaction{
-			TransactionID:   record[0],
-			Amount:          ToMoney(amountFloat),
-			Type:            TransactionType(strings.ToUpper(record[2])),
-			TransactionTime: tTime,
-		})
+	out := make([]CurrencyTotals, 0, len(byCurrency))
+	for _, t := range byCurrency {
+		out = append(out, *t)
 	}
-	return trxs, nil
+	sort.Slice(out, func(i, j int) bool { return out[i].Currency < out[j].Currency })
+	return out
 }
 
-func LoadBankStatement(r io.Reader, bankName string, start, end time.Time) ([]BankTransaction, error) {
-	csvReader := csv.NewReader(r)
+// overallTotals returns the discrepancy and fee totals of a result in a
+// single currency, and zero for a result in several currencies.
+func overallTotals(currencies []CurrencyTotals) (discrepancies, fees Money) {
+	if len(currencies) != 1 {
+		return 0, 0
+	}
+	return currencies[0].TotalDiscrepancies, currencies[0].TotalFees
+}
 
-	csvReader.TrimLeadingSpace = true
+// recordMatch adds a one-to-one match to the result and scores its
+// confidence, comparing the converted system amount for pairs across
+// currencies and the amount net of the fee for pairs with a bank fee.
+func recordMatch(result *ReconciliationResult, sys SystemTransaction, bankTrx BankTransaction, match Match) {
+	days := daysBetween(dayOf(sys.TransactionTime), bankTrx.Date)
 
-	if _, err := csvReader.Read(); err != nil {
-		return nil, err
+	sysAmount := getSignedAmount(sys) - match.Fee
+	if match.FX != nil {
+		sysAmount = match.FX.ConvertedAmount
 	}
 
-	var trxs []BankTransaction
+	result.Matched = append(result.Matched, MatchedPair{
+		System:     sys,
+		Bank:       bankTrx,
+		Rule:       match.Rule,
+		Confidence: matchConfidence(sysAmount, bankTrx.Amount, days),
+		FX:         match.FX,
+		Fee:        match.Fee,
+	})
+}
 
-	for {
-		record, err := csvReader.Read()
-		if err == io.EOF {
-			break
+// finalizeMatches moves matches scoring below minConfidence back into the
+// unmatched lists, then derives the match counters and discrepancies from the
+// matches that are kept.
+func finalizeMatches(result *ReconciliationResult, minConfidence float64) {
+	pairs, groups := result.Matched, result.GroupMatches
+	result.Matched, result.GroupMatches = nil, nil
+
+	for _, pair := range pairs {
+		if pair.Confidence < minConfidence {
+			result.UnmatchedSystem = append(result.UnmatchedSystem, pair.System)
+			result.UnmatchedBank[pair.Bank.BankName] = append(result.UnmatchedBank[pair.Bank.BankName], pair.Bank)
+			continue
 		}
 
-		dTime, _ := time.Parse(BankTimeFormat, record[2])
-
-		if dTime.Before(start) || dTime.After(end) {
+		if pair.FX != nil {
+			// the FX difference is reported on the pair, not as a discrepancy
+			result.Matched = append(result.Matched, pair)
 			continue
 		}
 
-		amountFloat, _ := strconv.ParseFloat(record[1], 64)
+		// a known bank fee is not a discrepancy
+		sysSignedAmount := getSignedAmount(pair.System)
+		diff := sysSignedAmount - pair.Fee - pair.Bank.Amount
+		if diff != 0 {
+			result.Discrepancies = append(result.Discrepancies, Discrepancy{
+				SystemID:     pair.System.TransactionID,
+				BankUniqueID: pair.Bank.UniqueID,
+				BankName:     pair.Bank.BankName,
+				Currency:     pair.Bank.Currency,
+				SystemAmount: sysSignedAmount,
+				BankAmount:   pair.Bank.Amount,
+				Difference:   diff,
+			})
+		}
 
-		trxs = append(trxs, BankTransaction{
-			BankName: bankName,
-			UniqueID: record[0],
-			Amount:   ToMoney(amountFloat),
-			Date:     dTime,
-		})
+		result.Matched = append(result.Matched, pair)
 	}
-	return trxs, nil
+
+	for _, group := range groups {
+		if group.Confidence < minConfidence {
+			result.UnmatchedSystem = append(result.UnmatchedSystem, group.System...)
+			for _, b := range group.Bank {
+				result.UnmatchedBank[b.BankName] = append(result.UnmatchedBank[b.BankName], b)
+			}
+			continue
+		}
+		result.GroupMatches = append(result.GroupMatches, group)
+	}
+
+	result.TotalMatched = len(result.Matched)
+	result.TotalGroupMatched = len(result.GroupMatches)
+}
+
+// LoadSystemTransactions loads a system export laid out as
+// trxID,amount,type,transactionTime. Rows that cannot be parsed are skipped;
+// use LoadSystemTransactionsWithProfile to collect them.
+func LoadSystemTransactions(r io.Reader, start, end time.Time) ([]SystemTransaction, error) {
+	trxs, _, err := LoadSystemTransactionsWithProfile(r, SystemDataFile, DefaultSystemProfile, start, end)
+	return trxs, err
+}
+
+// LoadBankStatement loads a bank statement laid out as
+// unique_identifier,amount,date. Rows that cannot be parsed are skipped; use
+// LoadBankStatementWithProfile to collect them.
+func LoadBankStatement(r io.Reader, bankName string, start, end time.Time) ([]BankTransaction, error) {
+	trxs, _, err := LoadBankStatementWithProfile(r, bankName, DefaultBankProfile, bankName, start, end, ReferenceRule{})
+	return trxs, err
 }
 
 func generateKey(date time.Time, amount Money) string {
@@ -236,6 +569,11 @@ func generateKey(date time.Time, amount Money) string {
 	return fmt.Sprintf("%s-%d", date.Format("2006-01-02 15:04"), amount)
 }
 
+// dayOf truncates t to its calendar day in its own zone.
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
 func getSignedAmount(sys SystemTransaction) Money {
 	rawAmount := sys.Amount
 	if rawAmount < 0 {
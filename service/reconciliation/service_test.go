@@ -1,7 +1,12 @@
 package reconciliation
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"mime/multipart"
 	"strings"
 	"testing"
@@ -37,6 +42,16 @@ func TestToMoney(t *testing.T) {
 			input:    0.01,
 			expected: 1,
 		},
+		{
+			name:     "float below the cent",
+			input:    0.29,
+			expected: 29,
+		},
+		{
+			name:     "large amount",
+			input:    1234567.89,
+			expected: 123456789,
+		},
 	}
 
 	for _, tt := range tests {
@@ -47,6 +62,101 @@ func TestToMoney(t *testing.T) {
 	}
 }
 
+func TestParseMoney(t *testing.T) {
+	indonesian := AmountFormat{DecimalSeparator: ",", ThousandsSeparator: "."}
+
+	tests := []struct {
+		name     string
+		input    string
+		format   AmountFormat
+		expected Money
+		wantErr  string
+	}{
+		{name: "plain", input: "0.29", expected: 29},
+		{name: "large", input: "1234567.89", expected: 123456789},
+		{name: "integer", input: "100", expected: 10000},
+		{name: "one decimal", input: "-50.5", expected: -5050},
+		{name: "leading decimal", input: ".5", expected: 50},
+		{name: "plus sign", input: "+1.00", expected: 100},
+		{name: "indonesian separators", input: "1.234.567,89", format: indonesian, expected: 123456789},
+		{name: "trailing minus", input: "1.234,50-", format: indonesian, expected: -123450},
+		{name: "parentheses", input: "(6,500)", format: AmountFormat{ThousandsSeparator: ","}, expected: -650000},
+		{name: "half up", input: "0.125", expected: 13},
+		{name: "half up negative", input: "-0.125", expected: -13},
+		{name: "half even down", input: "0.125", format: AmountFormat{Rounding: RoundHalfEven}, expected: 12},
+		{name: "half even up", input: "0.135", format: AmountFormat{Rounding: RoundHalfEven}, expected: 14},
+		{name: "half even above half", input: "0.1251", format: AmountFormat{Rounding: RoundHalfEven}, expected: 13},
+		{name: "round down", input: "0.129", format: AmountFormat{Rounding: RoundDown}, expected: 12},
+		{name: "exact with zeros", input: "0.1200", format: AmountFormat{Rounding: RoundExact}, expected: 12},
+		{name: "exact rejects", input: "0.125", format: AmountFormat{Rounding: RoundExact}, wantErr: `amount "0.125": more than 2 decimals`},
+		{name: "empty", input: "", wantErr: `invalid amount ""`},
+		{name: "letters", input: "12a", wantErr: `invalid amount "12a"`},
+		{name: "two decimal separators", input: "1.2.3", wantErr: `invalid amount "1.2.3"`},
+		{name: "thousands in decimals", input: "1,23.4", format: AmountFormat{DecimalSeparator: ",", ThousandsSeparator: "."}, wantErr: `invalid amount "1,23.4"`},
+		{name: "overflow", input: "92233720368547758.08", wantErr: `amount "92233720368547758.08" out of range`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMoney(tt.input, tt.format)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseCurrencyMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		expected Money
+	}{
+		{"100000", "IDR", 100000},
+		{"100000.00", "IDR", 100000},
+		{"100000.5", "IDR", 100001},
+		{"1.250", "KWD", 1250},
+		{"12.34", "USD", 1234},
+		{"12.34", "", 1234},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.input, func(t *testing.T) {
+			result, err := ParseCurrencyMoney(tt.input, tt.currency, AmountFormat{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	assert.Equal(t, "100.50", Money(10050).Format("USD"))
+	assert.Equal(t, "-0.05", Money(-5).Format(""))
+	assert.Equal(t, "100000", Money(100000).Format("IDR"))
+	assert.Equal(t, "1.250", Money(1250).Format("kwd"))
+	assert.Equal(t, "0.001", Money(1).Format("KWD"))
+}
+
+func TestTransactionJSON(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	data, err := json.Marshal(BankTransaction{BankName: "BCA", UniqueID: "B1", Amount: -100000, Currency: "IDR", Date: day})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Amount":-100000`)
+	assert.Contains(t, string(data), `"Currency":"IDR"`)
+
+	data, err = json.Marshal(SystemTransaction{TransactionID: "T1", Amount: 1250, Currency: "KWD", Type: Credit, TransactionTime: day})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Amount":1.250`)
+
+	data, err = json.Marshal(Discrepancy{Currency: "USD", SystemAmount: 1000, BankAmount: 950, Difference: 50})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Difference":0.50`)
+}
+
 func TestMoneyToFloat(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -199,6 +309,243 @@ BANK002,-50.25,2025-01-20`,
 	}
 }
 
+func TestLoadBankStatementReference(t *testing.T) {
+	csvData := `unique_id,amount,date,Remark
+BANK001,100.50,2025-01-15,TRF TRX001 PAYOUT
+BANK002,-50.25,2025-01-16,ATM WITHDRAWAL`
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 17, 23, 59, 59, 0, time.UTC)
+
+	t.Run("pattern capture group", func(t *testing.T) {
+		rule := ReferenceRule{Column: "remark", Pattern: `(TRX\d+)`}
+		transactions, _, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", DefaultBankProfile, "Test Bank", start, end, rule)
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.Equal(t, "TRF TRX001 PAYOUT", transactions[0].Description)
+		assert.Equal(t, "TRX001", transactions[0].Reference)
+		assert.Equal(t, "ATM WITHDRAWAL", transactions[1].Description)
+		assert.Empty(t, transactions[1].Reference)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		rule := ReferenceRule{Column: "remark", Pattern: `(TRX`}
+		_, _, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", DefaultBankProfile, "Test Bank", start, end, rule)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		rule := ReferenceRule{Column: "notes"}
+		transactions, _, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", DefaultBankProfile, "Test Bank", start, end, rule)
+
+		require.NoError(t, err)
+		assert.Empty(t, transactions[0].Reference)
+	})
+}
+
+func TestLoadBankStatementWithProfile(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 17, 23, 59, 59, 0, time.UTC)
+
+	t.Run("dr cr flag with comma decimals", func(t *testing.T) {
+		profile := BankProfile{
+			Name:               "bca",
+			BankName:           "BCA",
+			Delimiter:          ";",
+			Columns:            BankColumns{UniqueID: "Reference No", Amount: "Amount", DrCr: "DB/CR", Date: "Transaction Date", Description: "Remark"},
+			DateLayout:         "02/01/2006",
+			DecimalSeparator:   ",",
+			ThousandsSeparator: ".",
+			AmountSign:         DrCrFlag,
+			DebitFlag:          "DB",
+		}
+		csvData := `Transaction Date;Remark;Reference No;DB/CR;Amount
+15/01/2025;TRF TRX001;BANK001;CR;1.234,50
+16/01/2025;ATM;BANK002;DB;50,25
+16/01/2025;UNKNOWN;BANK003;XX;10,00`
+
+		transactions, parseErrors, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", profile.withDefaults(), "BCA", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.Equal(t, []ParseError{{File: "bank.csv", Line: 4, Column: "DB/CR", Value: "XX", Reason: "invalid debit/credit flag (expected DB or CR)"}}, parseErrors)
+		assert.Equal(t, "BANK001", transactions[0].UniqueID)
+		assert.Equal(t, Money(123450), transactions[0].Amount)
+		assert.Equal(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), transactions[0].Date)
+		assert.Equal(t, "TRF TRX001", transactions[0].Description)
+		assert.Equal(t, Money(-5025), transactions[1].Amount)
+	})
+
+	t.Run("debit and credit columns", func(t *testing.T) {
+		profile := BankProfile{
+			Name:       "mandiri",
+			Columns:    BankColumns{UniqueID: "ref", Debit: "debit", Credit: "credit", Date: "posting date"},
+			AmountSign: DebitCreditColumns,
+		}
+		csvData := `Posting Date,Ref,Debit,Credit
+2025-01-15,BANK001,,100.50
+2025-01-16,BANK002,50.25,
+2025-01-16,BANK003,,
+2025-01-16,BANK004,10.00,20.00
+2025-01-16,BANK005,0,20.00`
+
+		transactions, parseErrors, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", profile, "Mandiri", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 3)
+		assert.Equal(t, Money(10050), transactions[0].Amount)
+		assert.Equal(t, Money(-5025), transactions[1].Amount)
+		assert.Equal(t, "Mandiri", transactions[1].BankName)
+		assert.Equal(t, Money(2000), transactions[2].Amount)
+		assert.Equal(t, []ParseError{
+			{File: "bank.csv", Line: 4, Column: "Debit", Reason: "missing value"},
+			{File: "bank.csv", Line: 5, Column: "Credit", Value: "20.00", Reason: "both debit and credit set (expected only one)"},
+		}, parseErrors)
+	})
+
+	t.Run("currency column", func(t *testing.T) {
+		profile := BankProfile{Name: "multi", Currency: "IDR", Columns: BankColumns{UniqueID: "id", Amount: "amount", Date: "date", Currency: "ccy"}}
+		csvData := "id,amount,date,ccy\nB1,100000.00,2025-01-15,\nB2,12.50,2025-01-15,usd\nB3,1,2025-01-15,US"
+
+		transactions, parseErrors, err := LoadBankStatementWithProfile(strings.NewReader(csvData), "bank.csv", profile, "X", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.Equal(t, Money(100000), transactions[0].Amount)
+		assert.Equal(t, "IDR", transactions[0].Currency)
+		assert.Equal(t, Money(1250), transactions[1].Amount)
+		assert.Equal(t, "USD", transactions[1].Currency)
+		assert.Equal(t, []ParseError{{File: "bank.csv", Line: 4, Column: "ccy", Value: "US", Reason: "invalid currency (expected ISO 4217 code)"}}, parseErrors)
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		profile := BankProfile{Name: "x", Columns: BankColumns{UniqueID: "id", Amount: "value", Date: "date"}}
+		_, _, err := LoadBankStatementWithProfile(strings.NewReader("id,amount,date\nB1,1,2025-01-15"), "bank.csv", profile, "X", start, end, ReferenceRule{})
+
+		assert.EqualError(t, err, `column "value" not found`)
+	})
+}
+
+func TestLoadSystemTransactionsWithProfile(t *testing.T) {
+	profile := SystemProfile{
+		Name:        "ledger",
+		Delimiter:   "|",
+		Columns:     SystemColumns{TransactionID: "id", Amount: "amount", Type: "direction", TransactionTime: "booked_at"},
+		TimeLayout:  "2006-01-02T15:04:05",
+		TimeLayouts: []string{UnixLayout},
+	}
+	csvData := `booked_at|direction|amount|id
+2025-01-15T10:30:00|credit|100.50|TRX001
+2025-01-20T10:30:00|debit|50.25|TRX002
+1737000000|credit|10.00|TRX003`
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 17, 23, 59, 59, 0, time.UTC)
+
+	transactions, _, err := LoadSystemTransactionsWithProfile(strings.NewReader(csvData), SystemDataFile, profile, start, end)
+
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	assert.Equal(t, "TRX001", transactions[0].TransactionID)
+	assert.Equal(t, Money(10050), transactions[0].Amount)
+	assert.Equal(t, Credit, transactions[0].Type)
+	assert.Equal(t, "TRX003", transactions[1].TransactionID)
+	assert.Equal(t, time.Unix(1737000000, 0).Unix(), transactions[1].TransactionTime.Unix())
+}
+
+func TestLoadSystemTransactionsParseErrors(t *testing.T) {
+	csvData := `trxID,amount,type,transactionTime
+TRX001,100.50,CREDIT,2025-01-15 10:30:00
+TRX002,abc,DEBIT,2025-01-15 11:00:00
+TRX003,10.00,REFUND,2025-01-15 12:00:00
+TRX004,10.00,CREDIT,15/01/2025
+,10.00,CREDIT,2025-01-15 13:00:00
+TRX006,,DEBIT,2025-01-15 14:00:00
+TRX007,"10.00,CREDIT,2025-01-15 15:00:00`
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 15, 23, 59, 59, 0, time.UTC)
+
+	transactions, parseErrors, err := LoadSystemTransactionsWithProfile(strings.NewReader(csvData), SystemDataFile, DefaultSystemProfile, start, end)
+
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, "TRX001", transactions[0].TransactionID)
+
+	require.Len(t, parseErrors, 6)
+	assert.Equal(t, ParseError{File: SystemDataFile, Line: 3, Column: "amount", Value: "abc", Reason: "invalid amount"}, parseErrors[0])
+	assert.Equal(t, ParseError{File: SystemDataFile, Line: 4, Column: "type", Value: "REFUND", Reason: "invalid type (expected DEBIT or CREDIT)"}, parseErrors[1])
+	assert.Equal(t, ParseError{File: SystemDataFile, Line: 5, Column: "transactionTime", Value: "15/01/2025", Reason: "invalid time (expected one of layouts " + strings.Join(DefaultSystemTimeLayouts, ", ") + ")"}, parseErrors[2])
+	assert.Equal(t, ParseError{File: SystemDataFile, Line: 6, Column: "trxID", Reason: "missing value"}, parseErrors[3])
+	assert.Equal(t, ParseError{File: SystemDataFile, Line: 7, Column: "amount", Reason: "missing value"}, parseErrors[4])
+	assert.Equal(t, 8, parseErrors[5].Line)
+	assert.Equal(t, `system_data:3: column amount: invalid amount "abc"`, parseErrors[0].Error())
+}
+
+func TestParseTime(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name    string
+		value   string
+		layouts []string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "legacy", value: "2025-11-01 08:00:00", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "single digit hour", value: "2025-11-01 8:00:00", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "no zero padding", value: "2025-11-1 8:5:3", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 5, 3, 0, jakarta)},
+		{name: "no seconds", value: "2025-11-01 08:05", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 5, 0, 0, jakarta)},
+		{name: "rfc3339 with offset", value: "2025-11-01T01:00:00Z", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "rfc3339 without offset", value: "2025-11-01T08:00:00", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "unix seconds", value: "1761958800", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "unix millis", value: "1761958800500", layouts: []string{UnixMilliLayout}, want: time.Date(2025, 11, 1, 8, 0, 0, 500e6, jakarta)},
+		{name: "default unix millis", value: "1761958800500", layouts: DefaultSystemTimeLayouts, want: time.Date(2025, 11, 1, 8, 0, 0, 500e6, jakarta)},
+		{name: "unix seconds before millis", value: "1761958800", layouts: []string{UnixMilliLayout, UnixLayout}, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "custom layout", value: "01/11/2025 08.00", layouts: []string{"02/01/2006 15.04"}, want: time.Date(2025, 11, 1, 8, 0, 0, 0, jakarta)},
+		{name: "single layout error", value: "2025-11-01", layouts: []string{SystemTimeFormat}, wantErr: "invalid time (expected layout 2006-01-02 15:04:05)"},
+		{name: "layouts error", value: "yesterday", layouts: []string{SystemTimeFormat, UnixLayout}, wantErr: "invalid time (expected one of layouts 2006-01-02 15:04:05, unix)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.value, tt.layouts, jakarta)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %v", got)
+			assert.Equal(t, tt.want.Day(), got.Day(), "calendar day in the profile zone")
+		})
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	assert.NoError(t, DefaultBankProfile.Validate())
+	assert.NoError(t, DefaultSystemProfile.Validate())
+
+	tests := []struct {
+		name    string
+		profile BankProfile
+		wantErr string
+	}{
+		{"missing name", BankProfile{Columns: BankColumns{UniqueID: "0", Amount: "1", Date: "2"}}, "bank profile name is required"},
+		{"missing debit column", BankProfile{Name: "b", AmountSign: DebitCreditColumns, Columns: BankColumns{UniqueID: "0", Credit: "1", Date: "2"}}, `bank profile "b": debit and credit columns are required`},
+		{"missing flag column", BankProfile{Name: "b", AmountSign: DrCrFlag, Columns: BankColumns{UniqueID: "0", Amount: "1", Date: "2"}}, `bank profile "b": amount and dr_cr columns are required`},
+		{"unknown sign", BankProfile{Name: "b", AmountSign: "negative", Columns: BankColumns{UniqueID: "0", Amount: "1", Date: "2"}}, `bank profile "b": unknown amount sign "negative"`},
+		{"same separators", BankProfile{Name: "b", DecimalSeparator: ",", ThousandsSeparator: ",", Columns: BankColumns{UniqueID: "0", Amount: "1", Date: "2"}}, `bank profile "b": thousands separator must be a single character different from the decimal separator`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.profile.Validate(), tt.wantErr)
+		})
+	}
+
+	system := SystemProfile{Name: "s", TimeLayouts: []string{SystemTimeFormat, " "}, Columns: SystemColumns{TransactionID: "0", Amount: "1", Type: "2", TransactionTime: "3"}}
+	assert.EqualError(t, system.Validate(), `system profile "s": empty time layout`)
+}
+
 func TestGenerateKey(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -413,7 +760,7 @@ func TestReconcileProcess(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := reconcileProcess(tt.systemTransactions, tt.bankTransactions)
+			result := reconcileProcess(tt.systemTransactions, tt.bankTransactions, ReconcileOptions{})
 
 			assert.Equal(t, tt.expectedMatched, result.TotalMatched, "TotalMatched mismatch")
 			assert.Equal(t, tt.expectedUnmatched, result.TotalUnmatched, "TotalUnmatched mismatch")
@@ -429,99 +776,2307 @@ func TestReconcileProcess(t *testing.T) {
 	}
 }
 
-func TestReconcile(t *testing.T) {
+func TestReconcileProcessMatchedAndDiscrepancies(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 10000, Type: Debit, TransactionTime: day.Add(10 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 20000, Type: Debit, TransactionTime: day.Add(11 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: -10000, Date: day},
+		{BankName: "Bank A", UniqueID: "BANK002", Amount: -19500, Date: day},
+	}
+
+	result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
+
+	require.Len(t, result.Matched, 2)
+	assert.Equal(t, "SYS001", result.Matched[0].System.TransactionID)
+	assert.Equal(t, "BANK001", result.Matched[0].Bank.UniqueID)
+	assert.Equal(t, "SYS002", result.Matched[1].System.TransactionID)
+	assert.Equal(t, "BANK002", result.Matched[1].Bank.UniqueID)
+
+	require.Len(t, result.Discrepancies, 1)
+	assert.Equal(t, Discrepancy{
+		SystemID:     "SYS002",
+		BankUniqueID: "BANK002",
+		BankName:     "Bank A",
+		SystemAmount: -20000,
+		BankAmount:   -19500,
+		Difference:   -500,
+	}, result.Discrepancies[0])
+	assert.Equal(t, Money(500), result.TotalDiscrepancies)
+}
+
+func TestFindSubset(t *testing.T) {
 	tests := []struct {
-		name          string
-		startDate     string
-		endDate       string
-		sysCSV        string
-		bankCSV       string
-		expectError   bool
-		errorContains string
+		name     string
+		amounts  []Money
+		target   Money
+		maxSize  int
+		expected []int
 	}{
 		{
-			name:      "valid date range and data",
-			startDate: "2025-01-15",
-			endDate:   "2025-01-17",
-			sysCSV: `trx_id,amount,type,timestamp
-SYS001,100.50,CREDIT,2025-01-15 10:30:00`,
-			bankCSV: `unique_id,amount,date
-BANK001,100.50,2025-01-15`,
-			expectError: false,
+			name:     "pair found",
+			amounts:  []Money{300, 500, 700},
+			target:   1000,
+			maxSize:  2,
+			expected: []int{0, 2},
 		},
 		{
-			name:          "invalid start date format",
-			startDate:     "15-01-2025",
-			endDate:       "2025-01-17",
-			sysCSV:        "trx_id,amount,type,timestamp",
-			bankCSV:       "unique_id,amount,date",
-			expectError:   true,
-			errorContains: "invalid start_date",
+			name:     "smallest group preferred",
+			amounts:  []Money{100, 200, 300, 400},
+			target:   600,
+			maxSize:  3,
+			expected: []int{1, 3},
 		},
 		{
-			name:          "invalid end date format",
-			startDate:     "2025-01-15",
-			endDate:       "17/01/2025",
-			sysCSV:        "trx_id,amount,type,timestamp",
-			bankCSV:       "unique_id,amount,date",
-			expectError:   true,
-			errorContains: "invalid end_date",
+			name:     "group size capped",
+			amounts:  []Money{100, 200, 300},
+			target:   600,
+			maxSize:  2,
+			expected: nil,
 		},
 		{
-			name:      "empty system transactions",
-			startDate: "2025-01-15",
-			endDate:   "2025-01-17",
-			sysCSV:    "trx_id,amount,type,timestamp",
-			bankCSV: `unique_id,amount,date
-BANK001,100.50,2025-01-15`,
-			expectError: false,
+			name:     "three way split",
+			amounts:  []Money{100, 200, 300},
+			target:   600,
+			maxSize:  3,
+			expected: []int{0, 1, 2},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewReconciliationService()
+			result := findSubset(tt.amounts, tt.target, tt.maxSize)
+			if tt.expected == nil {
+				assert.Nil(t, result)
+				return
+			}
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
 
-			sysReader := strings.NewReader(tt.sysCSV)
+func TestReconcileProcessSplitMatching(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "PAYOUT", Amount: 100000, Type: Debit, TransactionTime: day.Add(9 * time.Hour)},
+		{TransactionID: "REFUND1", Amount: 20000, Type: Credit, TransactionTime: day.Add(10 * time.Hour)},
+		{TransactionID: "REFUND2", Amount: 30000, Type: Credit, TransactionTime: day.Add(11 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "PART1", Amount: -60000, Date: day},
+		{BankName: "Bank A", UniqueID: "PART2", Amount: -40000, Date: day},
+		{BankName: "Bank A", UniqueID: "BATCH", Amount: 50000, Date: day},
+	}
 
-			// Create multipart form with bank CSV
-			body := &bytes.Buffer{}
-			writer := multipart.NewWriter(body)
-			part, err := writer.CreateFormFile("bank_csv", "bank.csv")
-			require.NoError(t, err)
-			_, err = part.Write([]byte(tt.bankCSV))
-			require.NoError(t, err)
-			writer.Close()
+	t.Run("disabled by default", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
 
-			// Parse the multipart form
-			reader := multipart.NewReader(body, writer.Boundary())
-			form, err := reader.ReadForm(10 << 20)
-			require.NoError(t, err)
+		assert.Empty(t, result.GroupMatches)
+		assert.Equal(t, 0, result.TotalGroupMatched)
+	})
 
-			result, err := service.Reconcile(
-				tt.startDate,
-				tt.endDate,
-				sysReader,
-				form,
-				nil,
-				nil,
-			)
+	t.Run("one-to-many and many-to-one", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{MaxGroupSize: 2})
 
-			if tt.expectError {
-				assert.Error(t, err)
-				if tt.errorContains != "" {
-					assert.Contains(t, err.Error(), tt.errorContains)
-				}
-			} else {
-				assert.NoError(t, err)
-				assert.NotNil(t, result)
-			}
+		assert.Equal(t, 0, result.TotalMatched)
+		assert.Equal(t, 2, result.TotalGroupMatched)
+		assert.Equal(t, 0, result.TotalUnmatched)
+		require.Len(t, result.GroupMatches, 2)
+
+		assert.Len(t, result.GroupMatches[0].System, 1)
+		assert.Equal(t, "PAYOUT", result.GroupMatches[0].System[0].TransactionID)
+		assert.Len(t, result.GroupMatches[0].Bank, 2)
+
+		assert.Len(t, result.GroupMatches[1].System, 2)
+		require.Len(t, result.GroupMatches[1].Bank, 1)
+		assert.Equal(t, "BATCH", result.GroupMatches[1].Bank[0].UniqueID)
+	})
+}
+
+func TestSolveAssignment(t *testing.T) {
+	tests := []struct {
+		name     string
+		cost     [][]int64
+		expected []int
+	}{
+		{
+			name:     "square",
+			cost:     [][]int64{{4, 1, 3}, {2, 0, 5}, {3, 2, 2}},
+			expected: []int{1, 0, 2},
+		},
+		{
+			name:     "more columns than rows",
+			cost:     [][]int64{{9, 1, 9}, {1, 9, 9}},
+			expected: []int{1, 0},
+		},
+		{
+			name:     "more rows than columns",
+			cost:     [][]int64{{5}, {1}, {3}},
+			expected: []int{-1, 0, -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, solveAssignment(tt.cost))
 		})
 	}
 }
 
-func TestNewReconciliationService(t *testing.T) {
-	service := NewReconciliationService()
-	assert.NotNil(t, service)
-	assert.Implements(t, (*ReconciliationService)(nil), service)
+func TestAssignOptimalLargeAmounts(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 4e18, Type: Credit, TransactionTime: day},
+		{TransactionID: "SYS002", Amount: 4e18, Type: Credit, TransactionTime: day.AddDate(0, 0, 1)},
+	}
+	bankTransactions := []BankTransaction{
+		{UniqueID: "BANK001", Amount: -4e18, Date: day},
+		{UniqueID: "BANK002", Amount: -4e18, Date: day.AddDate(0, 0, 1)},
+	}
+
+	// SYS002 cannot reach BANK001, so both must pair on their own day
+	assigned := assignOptimal(systemTransactions, bankTransactions, ReconcileOptions{Window: SettlementWindow{MaxDays: 1}})
+
+	assert.Equal(t, map[int]int{0: 0, 1: 1}, assigned)
+}
+
+func TestAmountDistance(t *testing.T) {
+	assert.Equal(t, int64(150), amountDistance(100, -50))
+	assert.Equal(t, int64(150), amountDistance(-50, 100))
+	assert.Equal(t, int64(math.MaxInt64), amountDistance(math.MaxInt64, -1))
+	assert.Equal(t, int64(math.MaxInt64), amountDistance(math.MinInt64, 0))
+	assert.Equal(t, int64(math.MaxInt64), addCost(math.MaxInt64-1, 2))
+	assert.Equal(t, int64(math.MaxInt64), mulCost(math.MaxInt64/2+1, 2))
+}
+
+func TestReconcileProcessOptimalMode(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 10000, Type: Credit, TransactionTime: day.Add(9 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 50000, Type: Credit, TransactionTime: day.Add(10 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK002", Amount: 49900, Date: day},
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: 10100, Date: day},
+	}
+
+	t.Run("greedy pairs by row order", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
+
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Equal(t, Money(79800), result.TotalDiscrepancies)
+	})
+
+	t.Run("optimal minimises amount difference", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{Mode: MatchModeOptimal})
+
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Equal(t, Money(200), result.TotalDiscrepancies)
+		assert.Equal(t, MatchModeOptimal, result.MatchMode)
+	})
+
+	t.Run("optimal is independent of input order", func(t *testing.T) {
+		opts := ReconcileOptions{Mode: MatchModeOptimal, Window: SettlementWindow{MaxDays: 1}}
+		sys := []SystemTransaction{
+			systemTransactions[0],
+			systemTransactions[1],
+			{TransactionID: "SYS003", Amount: 10000, Type: Credit, TransactionTime: day.Add(11 * time.Hour)},
+		}
+		bank := []BankTransaction{
+			bankTransactions[0],
+			bankTransactions[1],
+			{BankName: "Bank A", UniqueID: "BANK003", Amount: 10050, Date: day.AddDate(0, 0, 1)},
+		}
+		reversedSys := []SystemTransaction{sys[2], sys[1], sys[0]}
+		reversedBank := []BankTransaction{bank[2], bank[1], bank[0]}
+
+		first := reconcileProcess(sys, bank, opts)
+		second := reconcileProcess(reversedSys, reversedBank, opts)
+
+		assert.Equal(t, first.Matched, second.Matched)
+		assert.Equal(t, first.Discrepancies, second.Discrepancies)
+	})
+}
+
+func TestReconcileProcessReferenceMatching(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "TRX001", Amount: 10000, Type: Debit, TransactionTime: day.Add(9 * time.Hour)},
+		{TransactionID: "TRX002", Amount: 20000, Type: Debit, TransactionTime: day.Add(10 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: -20000, Date: day},
+		{BankName: "Bank A", UniqueID: "BANK002", Amount: -9900, Date: day.AddDate(0, 0, 2), Reference: "TRX001"},
+	}
+
+	result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
+
+	assert.Equal(t, 2, result.TotalMatched)
+	require.Len(t, result.Matched, 2)
+	assert.Equal(t, "BANK002", result.Matched[0].Bank.UniqueID)
+	assert.Equal(t, RuleReference, result.Matched[0].Rule)
+	assert.Equal(t, "BANK001", result.Matched[1].Bank.UniqueID)
+	assert.Equal(t, RuleExactKey, result.Matched[1].Rule)
+
+	require.Len(t, result.Discrepancies, 1)
+	assert.Equal(t, Money(-100), result.Discrepancies[0].Difference)
+}
+
+func TestMatchConfidence(t *testing.T) {
+	tests := []struct {
+		name       string
+		sysAmount  Money
+		bankAmount Money
+		days       int
+		expected   float64
+	}{
+		{
+			name:       "exact same day",
+			sysAmount:  -10000,
+			bankAmount: -10000,
+			days:       0,
+			expected:   1,
+		},
+		{
+			name:       "amount difference",
+			sysAmount:  -10000,
+			bankAmount: -9000,
+			days:       0,
+			expected:   0.9,
+		},
+		{
+			name:       "date distance",
+			sysAmount:  10000,
+			bankAmount: 10000,
+			days:       2,
+			expected:   0.8,
+		},
+		{
+			name:       "opposite signs",
+			sysAmount:  10000,
+			bankAmount: -10000,
+			days:       0,
+			expected:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchConfidence(tt.sysAmount, tt.bankAmount, tt.days))
+		})
+	}
+}
+
+func TestReconcileProcessMinConfidence(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 10000, Type: Credit, TransactionTime: day.Add(9 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 20000, Type: Credit, TransactionTime: day.Add(10 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: 10000, Date: day},
+		{BankName: "Bank A", UniqueID: "BANK002", Amount: 5000, Date: day},
+	}
+
+	t.Run("scores every match", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
+
+		require.Len(t, result.Matched, 2)
+		assert.Equal(t, 1.0, result.Matched[0].Confidence)
+		assert.Equal(t, 0.25, result.Matched[1].Confidence)
+		assert.Equal(t, Money(15000), result.TotalDiscrepancies)
+	})
+
+	t.Run("low confidence goes back to unmatched", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{MinConfidence: 0.5})
+
+		assert.Equal(t, 1, result.TotalMatched)
+		assert.Equal(t, 2, result.TotalUnmatched)
+		assert.Empty(t, result.Discrepancies)
+		assert.Equal(t, Money(0), result.TotalDiscrepancies)
+		require.Len(t, result.UnmatchedSystem, 1)
+		assert.Equal(t, "SYS002", result.UnmatchedSystem[0].TransactionID)
+		require.Len(t, result.UnmatchedBank["Bank A"], 1)
+		assert.Equal(t, "BANK002", result.UnmatchedBank["Bank A"][0].UniqueID)
+	})
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         []Rule
+		errorContains string
+	}{
+		{
+			name:  "valid rules",
+			rules: []Rule{{Name: "bca", Bank: "BCA", Tolerance: Tolerance{Percent: 0.5}}, {Name: "any"}},
+		},
+		{
+			name:          "missing name",
+			rules:         []Rule{{Bank: "BCA"}},
+			errorContains: "name is required",
+		},
+		{
+			name:          "duplicate name",
+			rules:         []Rule{{Name: "bca"}, {Name: "bca"}},
+			errorContains: "duplicate",
+		},
+		{
+			name:          "reserved name",
+			rules:         []Rule{{Name: string(RuleExactKey)}},
+			errorContains: "reserved",
+		},
+		{
+			name:          "negative window",
+			rules:         []Rule{{Name: "bca", Window: SettlementWindow{MaxDays: -1}}},
+			errorContains: "settlement days",
+		},
+		{
+			name:          "window too long",
+			rules:         []Rule{{Name: "bca", Window: SettlementWindow{MaxDays: MaxSettlementDays + 1}}},
+			errorContains: "settlement days must not exceed 31",
+		},
+		{
+			name:          "percent above 100",
+			rules:         []Rule{{Name: "bca", Tolerance: Tolerance{Percent: 150}}},
+			errorContains: "exceed 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRules(tt.rules)
+			if tt.errorContains == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+}
+
+func TestReconcileWithRules(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 100000, Type: Debit, TransactionTime: day.Add(9 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 200000, Type: Debit, TransactionTime: day.Add(10 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "BCA", UniqueID: "BCA001", Amount: -99600, Date: day},
+		{BankName: "BRI", UniqueID: "BRI001", Amount: -199000, Date: day.AddDate(0, 0, 1)},
+	}
+
+	service := NewReconciliationService(WithRules(
+		Rule{Name: "bca_half_percent", Bank: "bca", Tolerance: Tolerance{Percent: 0.5}},
+		Rule{Name: "next_day_1000", Window: SettlementWindow{MaxDays: 1}, Tolerance: Tolerance{Amount: 1000}},
+	)).(*reconciliationService)
+
+	assert.Len(t, service.Rules(), 2)
+	require.Len(t, service.matchers, len(DefaultMatchers())+2)
+	assert.Equal(t, "next_day_1000", service.matchers[len(service.matchers)-3].Name())
+
+	result := reconcileWith(service.matchers, systemTransactions, bankTransactions, ReconcileOptions{})
+
+	require.Len(t, result.Matched, 2)
+	assert.Equal(t, MatchRule("bca_half_percent"), result.Matched[0].Rule)
+	assert.Equal(t, "BCA001", result.Matched[0].Bank.UniqueID)
+	assert.Equal(t, MatchRule("next_day_1000"), result.Matched[1].Rule)
+	assert.Equal(t, "BRI001", result.Matched[1].Bank.UniqueID)
+
+	t.Run("no tolerance is exact", func(t *testing.T) {
+		matchers := []Matcher{RuleMatcher(Rule{Name: "next_day_exact", Window: SettlementWindow{MaxDays: 1}})}
+
+		result := reconcileWith(matchers, systemTransactions, bankTransactions, ReconcileOptions{})
+		assert.Empty(t, result.Matched)
+
+		exact := append([]BankTransaction(nil), bankTransactions...)
+		exact[1].Amount = -200000
+		result = reconcileWith(matchers, systemTransactions, exact, ReconcileOptions{})
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, "BRI001", result.Matched[0].Bank.UniqueID)
+	})
+}
+
+func TestToleranceAllows(t *testing.T) {
+	tests := []struct {
+		name      string
+		tolerance Tolerance
+		sysAmount Money
+		bankAmt   Money
+		expected  bool
+	}{
+		{
+			name:      "zero tolerance is unbounded",
+			tolerance: Tolerance{},
+			sysAmount: 10000,
+			bankAmt:   1,
+			expected:  true,
+		},
+		{
+			name:      "within absolute tolerance",
+			tolerance: Tolerance{Amount: 500},
+			sysAmount: -10000,
+			bankAmt:   -9500,
+			expected:  true,
+		},
+		{
+			name:      "beyond absolute tolerance",
+			tolerance: Tolerance{Amount: 500},
+			sysAmount: -10000,
+			bankAmt:   -9499,
+			expected:  false,
+		},
+		{
+			name:      "within percent tolerance",
+			tolerance: Tolerance{Percent: 1},
+			sysAmount: 10000,
+			bankAmt:   9900,
+			expected:  true,
+		},
+		{
+			name:      "looser bound wins",
+			tolerance: Tolerance{Amount: 10, Percent: 5},
+			sysAmount: 10000,
+			bankAmt:   9600,
+			expected:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.tolerance.Allows(tt.sysAmount, tt.bankAmt))
+		})
+	}
+}
+
+func TestReconcileProcessTolerance(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 10000, Type: Credit, TransactionTime: day.Add(10 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 50000, Type: Credit, TransactionTime: day.Add(11 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: 9900, Date: day},
+		{BankName: "Bank B", UniqueID: "BANK002", Amount: 40000, Date: day},
+	}
+
+	t.Run("beyond tolerance stays unmatched", func(t *testing.T) {
+		opts := ReconcileOptions{Tolerance: Tolerance{Amount: 100}}
+		result := reconcileProcess(systemTransactions, bankTransactions, opts)
+
+		assert.Equal(t, 1, result.TotalMatched)
+		assert.Equal(t, Money(100), result.TotalDiscrepancies)
+		require.Len(t, result.UnmatchedSystem, 1)
+		assert.Equal(t, "SYS002", result.UnmatchedSystem[0].TransactionID)
+		assert.Len(t, result.UnmatchedBank["Bank B"], 1)
+		assert.Equal(t, opts.Tolerance, result.Tolerance)
+	})
+
+	t.Run("bank tolerance overrides request tolerance", func(t *testing.T) {
+		opts := ReconcileOptions{
+			Tolerance:      Tolerance{Amount: 100},
+			BankTolerances: map[string]Tolerance{"Bank B": {Percent: 25}},
+		}
+		result := reconcileProcess(systemTransactions, bankTransactions, opts)
+
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Equal(t, Money(10100), result.TotalDiscrepancies)
+		assert.Empty(t, result.UnmatchedSystem)
+		assert.Equal(t, opts.BankTolerances, result.BankTolerances)
+	})
+}
+
+func TestSettlementWindowDates(t *testing.T) {
+	friday := time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   SettlementWindow
+		expected []string
+	}{
+		{
+			name:     "same day only",
+			window:   SettlementWindow{},
+			expected: []string{"2025-01-17"},
+		},
+		{
+			name:     "calendar days",
+			window:   SettlementWindow{MaxDays: 2},
+			expected: []string{"2025-01-17", "2025-01-18", "2025-01-19"},
+		},
+		{
+			name:     "skip weekends",
+			window:   SettlementWindow{MaxDays: 2, SkipWeekends: true},
+			expected: []string{"2025-01-17", "2025-01-20", "2025-01-21"},
+		},
+		{
+			name: "skip weekends and holidays",
+			window: SettlementWindow{
+				MaxDays:      2,
+				SkipWeekends: true,
+				Holidays:     []time.Time{time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)},
+			},
+			expected: []string{"2025-01-17", "2025-01-21", "2025-01-22"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range tt.window.dates(friday) {
+				got = append(got, d.Format(BankTimeFormat))
+			}
+			assert.Equal(t, tt.expected, got)
+			assert.Equal(t, tt.expected[len(tt.expected)-1], tt.window.lastDate(friday).Format(BankTimeFormat))
+		})
+	}
+}
+
+func TestReconcileSettlementWindowTooLong(t *testing.T) {
+	service := NewReconciliationService()
+
+	_, err := service.Reconcile("2025-01-15", "2025-01-15", nil, nil, nil, nil, ReconcileOptions{Window: SettlementWindow{MaxDays: 100000000}})
+
+	assert.EqualError(t, err, "invalid settlement_days (expected integer between 0 and 31)")
+}
+
+func TestReconcileGroupSizeTooLarge(t *testing.T) {
+	service := NewReconciliationService()
+
+	_, err := service.Reconcile("2025-01-15", "2025-01-15", nil, nil, nil, nil, ReconcileOptions{MaxGroupSize: 25})
+
+	assert.EqualError(t, err, "invalid max_group_size (expected integer between 0 and 5)")
+}
+
+func TestReconcileProcessSettlementWindow(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "SYS001", Amount: 10000, Type: Credit, TransactionTime: day.Add(10 * time.Hour)},
+		{TransactionID: "SYS002", Amount: 20000, Type: Credit, TransactionTime: day.Add(11 * time.Hour)},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "BANK003", Amount: 10000, Date: day.AddDate(0, 0, 3)},
+		{BankName: "Bank A", UniqueID: "BANK001", Amount: 10000, Date: day.AddDate(0, 0, 1)},
+		{BankName: "Bank A", UniqueID: "BANK002", Amount: 19000, Date: day.AddDate(0, 0, 2)},
+	}
+
+	t.Run("no window keeps same day matching", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{})
+
+		assert.Equal(t, 0, result.TotalMatched)
+		assert.Len(t, result.UnmatchedSystem, 2)
+	})
+
+	t.Run("window prefers closest date", func(t *testing.T) {
+		opts := ReconcileOptions{Window: SettlementWindow{MaxDays: 3}}
+		result := reconcileProcess(systemTransactions, bankTransactions, opts)
+
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Equal(t, Money(1000), result.TotalDiscrepancies)
+		require.Len(t, result.UnmatchedBank["Bank A"], 1)
+		assert.Equal(t, "BANK003", result.UnmatchedBank["Bank A"][0].UniqueID)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name          string
+		startDate     string
+		endDate       string
+		sysCSV        string
+		bankCSV       string
+		expectError   bool
+		errorContains string
+	}{
+		{
+			name:      "valid date range and data",
+			startDate: "2025-01-15",
+			endDate:   "2025-01-17",
+			sysCSV: `trx_id,amount,type,timestamp
+SYS001,100.50,CREDIT,2025-01-15 10:30:00`,
+			bankCSV: `unique_id,amount,date
+BANK001,100.50,2025-01-15`,
+			expectError: false,
+		},
+		{
+			name:          "invalid start date format",
+			startDate:     "15-01-2025",
+			endDate:       "2025-01-17",
+			sysCSV:        "trx_id,amount,type,timestamp",
+			bankCSV:       "unique_id,amount,date",
+			expectError:   true,
+			errorContains: "invalid start_date",
+		},
+		{
+			name:          "invalid end date format",
+			startDate:     "2025-01-15",
+			endDate:       "17/01/2025",
+			sysCSV:        "trx_id,amount,type,timestamp",
+			bankCSV:       "unique_id,amount,date",
+			expectError:   true,
+			errorContains: "invalid end_date",
+		},
+		{
+			name:      "empty system transactions",
+			startDate: "2025-01-15",
+			endDate:   "2025-01-17",
+			sysCSV:    "trx_id,amount,type,timestamp",
+			bankCSV: `unique_id,amount,date
+BANK001,100.50,2025-01-15`,
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewReconciliationService()
+
+			sysReader := strings.NewReader(tt.sysCSV)
+
+			// Create multipart form with bank CSV
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, err := writer.CreateFormFile("bank_csv", "bank.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(tt.bankCSV))
+			require.NoError(t, err)
+			writer.Close()
+
+			// Parse the multipart form
+			reader := multipart.NewReader(body, writer.Boundary())
+			form, err := reader.ReadForm(10 << 20)
+			require.NoError(t, err)
+
+			result, err := service.Reconcile(
+				tt.startDate,
+				tt.endDate,
+				sysReader,
+				form,
+				nil,
+				nil,
+				ReconcileOptions{},
+			)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				if tt.errorContains != "" {
+					assert.Contains(t, err.Error(), tt.errorContains)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, result)
+			}
+		})
+	}
+}
+
+func TestNewReconciliationService(t *testing.T) {
+	service := NewReconciliationService()
+	assert.NotNil(t, service)
+	assert.Implements(t, (*ReconciliationService)(nil), service)
+	assert.Len(t, service.(*reconciliationService).matchers, len(DefaultMatchers()))
+
+	custom := NewReconciliationService(WithMatchers(ExactKeyMatcher()))
+	assert.Len(t, custom.(*reconciliationService).matchers, 1)
+}
+
+func TestReconcileWithProfiles(t *testing.T) {
+	service := NewReconciliationService(WithBankProfiles(BankProfile{
+		Name:       "mandiri",
+		BankName:   "Mandiri",
+		Columns:    BankColumns{UniqueID: "Ref", Debit: "Debit", Credit: "Credit", Date: "Posting Date"},
+		AmountSign: DebitCreditColumns,
+	}))
+
+	newForm := func() *multipart.Form {
+		return newBankForm(t, "mandiri.csv", "Posting Date,Ref,Debit,Credit\n2025-01-15,BANK001,,100.50\n")
+	}
+	sysData := "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\n"
+
+	t.Run("selected profile", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newForm(), nil, nil, ReconcileOptions{BankProfiles: []string{"mandiri"}})
+
+		require.NoError(t, err)
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, "Mandiri", result.Matched[0].Bank.BankName)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newForm(), nil, nil, ReconcileOptions{BankProfiles: []string{"bri"}})
+
+		assert.EqualError(t, err, `unknown bank profile "bri"`)
+	})
+}
+
+// idMatcher pairs transactions whose system ID equals the bank unique ID.
+type idMatcher struct {
+	extra []Match
+}
+
+func (idMatcher) Name() string {
+	return "same_id"
+}
+
+func (m idMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	for i, sys := range system {
+		for j, b := range bank {
+			if sys.TransactionID == b.UniqueID {
+				matches = append(matches, Match{System: []int{i}, Bank: []int{j}})
+			}
+		}
+	}
+	return append(matches, m.extra...)
+}
+
+func TestReconcileProcessCurrencies(t *testing.T) {
+	day := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	bankDay := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "T1", Amount: 100000, Currency: "IDR", Type: Credit, TransactionTime: day},
+		{TransactionID: "T2", Amount: 100000, Currency: "usd", Type: Credit, TransactionTime: day},
+		{TransactionID: "T3", Amount: 5000, Type: Credit, TransactionTime: day},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "BCA", UniqueID: "B1", Amount: 100000, Currency: "USD", Date: bankDay},
+		{BankName: "BCA", UniqueID: "B2", Amount: 99500, Currency: "IDR", Date: bankDay},
+		{BankName: "BCA", UniqueID: "B3", Amount: 5000, Date: bankDay},
+	}
+
+	t.Run("never across currencies", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{Currency: "IDR", Tolerance: Tolerance{Amount: 50000}})
+
+		require.Len(t, result.Matched, 3)
+		for _, pair := range result.Matched {
+			assert.Equal(t, pair.System.Currency, pair.Bank.Currency)
+		}
+		assert.Equal(t, "B1", result.Matched[0].Bank.UniqueID)
+		assert.Equal(t, "B3", result.Matched[1].Bank.UniqueID)
+		assert.Equal(t, "B2", result.Matched[2].Bank.UniqueID)
+		require.Len(t, result.Discrepancies, 1)
+		assert.Equal(t, "IDR", result.Discrepancies[0].Currency)
+		assert.Equal(t, []CurrencyTotals{
+			{Currency: "IDR", TotalMatched: 2, TotalDiscrepancies: 500},
+			{Currency: "USD", TotalMatched: 1},
+		}, result.Currencies)
+
+		// IDR and USD amounts do not add up
+		assert.Equal(t, Money(0), result.TotalDiscrepancies)
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"TotalDiscrepancies":null,"TotalFees":null`)
+	})
+
+	t.Run("single currency totals", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions[:1], bankTransactions[1:2], ReconcileOptions{Tolerance: Tolerance{Amount: 50000}})
+
+		assert.Equal(t, Money(500), result.TotalDiscrepancies)
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"TotalDiscrepancies":500,"TotalFees":0`)
+	})
+
+	t.Run("tolerance in hundredths of the major unit", func(t *testing.T) {
+		// 4.00 allows a 4 IDR difference, not 400 IDR
+		result := reconcileProcess(systemTransactions[:1], bankTransactions[1:2], ReconcileOptions{Tolerance: Tolerance{Amount: 400}})
+
+		assert.Empty(t, result.Matched)
+		assert.Equal(t, []CurrencyTotals{{Currency: "IDR", TotalUnmatched: 2}}, result.Currencies)
+	})
+}
+
+func TestReconcileWithCustomMatchers(t *testing.T) {
+	day := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "A", Amount: 10000, Type: Credit, TransactionTime: day},
+		{TransactionID: "B", Amount: 20000, Type: Credit, TransactionTime: day},
+		{TransactionID: "C", Amount: 30000, Type: Credit, TransactionTime: day},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "Bank A", UniqueID: "B", Amount: 25000, Date: day.AddDate(0, 0, 5)},
+		{BankName: "Bank A", UniqueID: "X", Amount: 10000, Date: day},
+		{BankName: "Bank A", UniqueID: "Y", Amount: 30000, Date: day},
+	}
+
+	t.Run("custom matcher runs before built-ins", func(t *testing.T) {
+		matchers := append([]Matcher{idMatcher{}}, DefaultMatchers()...)
+		result := reconcileWith(matchers, systemTransactions, bankTransactions, ReconcileOptions{})
+
+		require.Len(t, result.Matched, 3)
+		assert.Equal(t, MatchRule("same_id"), result.Matched[0].Rule)
+		assert.Equal(t, "B", result.Matched[0].System.TransactionID)
+		assert.Equal(t, RuleExactKey, result.Matched[1].Rule)
+		assert.Equal(t, RuleExactKey, result.Matched[2].Rule)
+		assert.Equal(t, 0, result.TotalUnmatched)
+	})
+
+	t.Run("invalid and overlapping matches are ignored", func(t *testing.T) {
+		matcher := idMatcher{extra: []Match{
+			{System: []int{1}, Bank: []int{2}},
+			{System: []int{5}, Bank: []int{0}},
+			{System: []int{0}},
+			{System: []int{2}, Bank: []int{2}, Rule: "custom_rule"},
+		}}
+		result := reconcileWith([]Matcher{matcher}, systemTransactions, bankTransactions, ReconcileOptions{})
+
+		require.Len(t, result.Matched, 2)
+		assert.Equal(t, "B", result.Matched[0].Bank.UniqueID)
+		assert.Equal(t, MatchRule("custom_rule"), result.Matched[1].Rule)
+		assert.Len(t, result.UnmatchedSystem, 1)
+	})
+}
+
+func TestDetectBankProfile(t *testing.T) {
+	bca := BankProfile{
+		Name:            "bca",
+		FilenamePattern: "BCA_Statement*",
+		Delimiter:       ";",
+		Columns:         BankColumns{UniqueID: "Reference No", Amount: "Amount", DrCr: "DB/CR", Date: "Transaction Date"},
+		AmountSign:      DrCrFlag,
+	}
+	generic := BankProfile{
+		Name:    "generic",
+		Columns: BankColumns{UniqueID: "unique_identifier", Amount: "amount", Date: "date"},
+	}
+	bri := BankProfile{
+		Name:            "bri",
+		FilenamePattern: "bri_*",
+		Columns:         BankColumns{UniqueID: "unique_identifier", Amount: "amount", Date: "date"},
+	}
+	service := NewReconciliationService(WithBankProfiles(bca, generic, bri)).(*reconciliationService)
+
+	tests := []struct {
+		name     string
+		filename string
+		header   string
+		expected string
+		wantErr  string
+	}{
+		{"header names", "statement.csv", "Transaction Date;Reference No;DB/CR;Amount\n", "bca", ""},
+		{"named columns beat positions", "statement.csv", "unique_identifier,amount,date\n", "generic", ""},
+		{"filename pattern", "BRI_Statement - Sheet1.csv", "unique_identifier,amount,date\n", "bri", ""},
+		{"legacy header", "statement.csv", "unique_id,amount,date\n", DefaultProfileName, ""},
+		{"unknown header", "statement.csv", "id,value,posted,balance\n", "", `no bank profile fits header "id,value,posted,balance"`},
+		{"no profile fits", "statement.csv", "id;value\n", "", `no bank profile fits header "id;value"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := service.detectBankProfile(tt.filename, tt.header, nil)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, profile.Name)
+		})
+	}
+}
+
+func TestReconcileFileReports(t *testing.T) {
+	service := NewReconciliationService()
+	sysData := "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\n"
+
+	t.Run("detected profile", func(t *testing.T) {
+		form := newBankForm(t, "bank.csv", "unique_id,amount,date\nBANK001,100.50,2025-01-15\n")
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), form, nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		assert.Equal(t, []FileReport{{FileName: "bank.csv", Format: BankFormatCSV, Profile: DefaultProfileName, BankName: "Stmt-bank.csv", Transactions: 1}}, result.Files)
+	})
+
+	t.Run("legacy layout without detection", func(t *testing.T) {
+		form := newBankForm(t, "bank.csv", "id,amt,dt\nBANK001,100.50,2025-01-15\n")
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), form, nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		assert.Equal(t, []FileReport{{FileName: "bank.csv", Format: BankFormatCSV, Profile: DefaultProfileName, BankName: "Stmt-bank.csv", Transactions: 1}}, result.Files)
+		assert.Empty(t, result.ParseErrors)
+		assert.Equal(t, 1, result.TotalMatched)
+	})
+
+	t.Run("no profile fits", func(t *testing.T) {
+		form := newBankForm(t, "bank.csv", "unique_id;amount;date\nBANK001;100.50;2025-01-15\n")
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), form, nil, nil, ReconcileOptions{BankProfiles: []string{AutoProfileName}})
+
+		require.NoError(t, err)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, `no bank profile fits header "unique_id;amount;date"`, result.Files[0].Error)
+		assert.Empty(t, result.Files[0].Profile)
+		assert.Len(t, result.UnmatchedSystem, 1)
+	})
+
+	t.Run("header after byte order mark and blank lines", func(t *testing.T) {
+		service := NewReconciliationService(WithBankProfiles(BankProfile{
+			Name:    "generic",
+			Columns: BankColumns{UniqueID: "unique_identifier", Amount: "amount", Date: "date"},
+		}))
+		form := newBankForm(t, "bank.csv", "\ufeff\n \r\nunique_identifier,amount,date\nBANK001,100.50,2025-01-15\nBANK002,1.00,yesterday\n")
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), form, nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, "generic", result.Files[0].Profile)
+		assert.Equal(t, 1, result.TotalMatched)
+		require.Len(t, result.ParseErrors, 1)
+		assert.Equal(t, 5, result.ParseErrors[0].Line)
+	})
+}
+
+func TestReconcileParseErrors(t *testing.T) {
+	service := NewReconciliationService()
+	sysData := "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\nTRX002,abc,CREDIT,2025-01-15 10:30:00\n"
+	bankData := "unique_id,amount,date\nBANK001,100.50,2025-01-15\nBANK002,1.00,yesterday\n"
+
+	t.Run("errors are reported", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankData), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.TotalMatched)
+		require.Len(t, result.ParseErrors, 2)
+		assert.Equal(t, SystemDataFile, result.ParseErrors[0].File)
+		assert.Equal(t, ParseError{File: "bank.csv", Line: 3, Column: "date", Value: "yesterday", Reason: "invalid date (expected layout 2006-01-02)"}, result.ParseErrors[1])
+	})
+
+	t.Run("unreadable file", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newBankForm(t, "bank.csv", ""), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		require.Len(t, result.ParseErrors, 2)
+		assert.Equal(t, ParseError{File: "bank.csv", Reason: "failed to read header: missing header row"}, result.ParseErrors[1])
+	})
+
+	t.Run("strict mode", func(t *testing.T) {
+		_, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankData), nil, nil, ReconcileOptions{Strict: true})
+
+		var strictErr *StrictModeError
+		require.ErrorAs(t, err, &strictErr)
+		assert.Len(t, strictErr.Errors, 2)
+		assert.EqualError(t, err, `strict mode: 2 invalid rows, first: system_data:3: column amount: invalid amount "abc"`)
+	})
+}
+
+// newBankForm builds a parsed multipart form holding one bank_csv upload.
+func newBankForm(t *testing.T, filename, content string) *multipart.Form {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("bank_csv", filename)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	writer.Close()
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(10 << 20)
+	require.NoError(t, err)
+	return form
+}
+
+func TestLoadFXRates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "csv",
+			data: "\ufeffdate,from,to,rate\n2025-01-01,usd,IDR,15850\n2025-01-03,USD,IDR,15875.5\n",
+		},
+		{
+			name: "json",
+			data: ` [{"date":"2025-01-03","from":"USD","to":"IDR","rate":15875.5},{"date":"2025-01-01","from":"USD","to":"IDR","rate":"15850"}]`,
+		},
+		{
+			name:    "invalid date",
+			data:    "date,from,to,rate\n01/01/2025,USD,IDR,15850\n",
+			wantErr: `fx rates line 2: invalid date "01/01/2025" (expected YYYY-MM-DD)`,
+		},
+		{
+			name:    "invalid rate",
+			data:    "date,from,to,rate\n2025-01-01,USD,IDR,-1\n",
+			wantErr: `fx rate 1: invalid rate "-1" (expected positive decimal)`,
+		},
+		{
+			name:    "same currency",
+			data:    `[{"date":"2025-01-01","from":"IDR","to":"IDR","rate":1}]`,
+			wantErr: `fx rate 1: invalid currency pair "IDR"/"IDR"`,
+		},
+		{
+			name:    "missing column",
+			data:    "date,from,to\n",
+			wantErr: `invalid fx rates CSV: column "rate" not found`,
+		},
+		{
+			name:    "empty",
+			data:    " \n",
+			wantErr: "empty fx rates file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := LoadFXRates(strings.NewReader(tt.data))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			_, ok := rates.lookup("USD", "IDR", day(1).Add(-time.Hour))
+			assert.False(t, ok, "no rate before the first date")
+
+			rate, ok := rates.lookup("USD", "IDR", day(2))
+			require.True(t, ok)
+			assert.Equal(t, "15850", rate.text)
+			assert.Equal(t, day(1), rate.date)
+
+			rate, ok = rates.lookup("USD", "IDR", day(20))
+			require.True(t, ok)
+			assert.Equal(t, "15875.5", rate.text)
+
+			rate, ok = rates.lookup("IDR", "USD", day(2))
+			require.True(t, ok, "inverse of the reverse pair")
+			assert.Equal(t, "1/15850", rate.text)
+		})
+	}
+}
+
+func TestFXConvert(t *testing.T) {
+	rates := &FXRates{}
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, rates.Add(FXRate{Date: day, From: "USD", To: "IDR", Rate: "15875.5"}))
+	require.NoError(t, rates.Add(FXRate{Date: day, From: "USD", To: "KWD", Rate: "0.3081"}))
+
+	tests := []struct {
+		name     string
+		amount   Money
+		from, to string
+		want     Money
+	}{
+		{name: "cents to rupiah", amount: 10001, from: "USD", to: "IDR", want: 1587709},
+		{name: "negative rounds away from zero", amount: -3, from: "USD", to: "IDR", want: -476},
+		{name: "inverse rate", amount: 1587550, from: "IDR", to: "USD", want: 10000},
+		{name: "three decimals", amount: 10000, from: "USD", to: "KWD", want: 30810},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, ok := rates.lookup(tt.from, tt.to, day)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, rate.convert(tt.amount, tt.from, tt.to))
+		})
+	}
+}
+
+func TestReconcileProcessFX(t *testing.T) {
+	day := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	bankDay := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	rates := &FXRates{}
+	require.NoError(t, rates.Add(FXRate{Date: day.AddDate(0, 0, -1), From: "USD", To: "IDR", Rate: "15850"}))
+
+	systemTransactions := []SystemTransaction{
+		{TransactionID: "T1", Amount: 10000, Currency: "USD", Type: Credit, TransactionTime: day},
+		{TransactionID: "T2", Amount: 500, Currency: "SGD", Type: Credit, TransactionTime: day},
+	}
+	bankTransactions := []BankTransaction{
+		{BankName: "BCA", UniqueID: "B1", Amount: 1584000, Currency: "IDR", Date: bankDay},
+		{BankName: "BCA", UniqueID: "B2", Amount: 500, Currency: "IDR", Date: bankDay},
+	}
+	opts := ReconcileOptions{
+		Window:      SettlementWindow{MaxDays: 1},
+		FXRates:     rates,
+		FXTolerance: Tolerance{Amount: 150000},
+	}
+
+	t.Run("converted within tolerance", func(t *testing.T) {
+		result := reconcileProcess(systemTransactions, bankTransactions, opts)
+
+		require.Len(t, result.Matched, 1)
+		pair := result.Matched[0]
+		assert.Equal(t, RuleFX, pair.Rule)
+		assert.Equal(t, "B1", pair.Bank.UniqueID)
+		require.NotNil(t, pair.FX)
+		assert.Equal(t, FXConversion{
+			From:            "USD",
+			To:              "IDR",
+			Rate:            "15850",
+			RateDate:        time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC),
+			ConvertedAmount: 1585000,
+			Difference:      1000,
+		}, *pair.FX)
+		assert.Empty(t, result.Discrepancies)
+		assert.Equal(t, Money(0), result.TotalDiscrepancies)
+		assert.Contains(t, result.Currencies, CurrencyTotals{Currency: "IDR", TotalMatched: 1, TotalUnmatched: 1, TotalFXDifferences: 1000})
+
+		data, err := json.Marshal(pair.FX)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"ConvertedAmount":1585000,"Difference":1000`)
+	})
+
+	t.Run("beyond tolerance", func(t *testing.T) {
+		tight := opts
+		tight.FXTolerance = Tolerance{Amount: 50000}
+		result := reconcileProcess(systemTransactions, bankTransactions, tight)
+
+		assert.Empty(t, result.Matched)
+	})
+
+	t.Run("zero tolerance requires the converted amount", func(t *testing.T) {
+		exact := opts
+		exact.FXTolerance = Tolerance{}
+		result := reconcileProcess(systemTransactions, bankTransactions, exact)
+		assert.Empty(t, result.Matched)
+
+		bank := append([]BankTransaction{{BankName: "BCA", UniqueID: "B0", Amount: -99999999900, Currency: "IDR", Date: bankDay}}, bankTransactions...)
+		bank[1].Amount = 1585000
+		result = reconcileProcess(systemTransactions, bank, exact)
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, "B1", result.Matched[0].Bank.UniqueID)
+	})
+
+	t.Run("opposite sign", func(t *testing.T) {
+		bank := []BankTransaction{{BankName: "BCA", UniqueID: "B1", Amount: -1585000, Currency: "IDR", Date: bankDay}}
+		wide := opts
+		wide.FXTolerance = Tolerance{Percent: 300}
+		result := reconcileProcess(systemTransactions, bank, wide)
+
+		assert.Empty(t, result.Matched)
+	})
+
+	t.Run("disabled without rates", func(t *testing.T) {
+		noRates := opts
+		noRates.FXRates = nil
+		result := reconcileProcess(systemTransactions, bankTransactions, noRates)
+
+		assert.Empty(t, result.Matched)
+	})
+}
+
+func TestReconcileFXRatesUpload(t *testing.T) {
+	configured := &FXRates{}
+	require.NoError(t, configured.Add(FXRate{Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), From: "USD", To: "IDR", Rate: "15000"}))
+	service := NewReconciliationService(
+		WithFXRates(configured),
+		WithSystemProfiles(SystemProfile{Name: "usd", Columns: SystemColumns{TransactionID: "0", Amount: "1", Type: "2", TransactionTime: "3", Currency: "4"}}),
+		WithBankProfiles(BankProfile{Name: "idr", Columns: BankColumns{UniqueID: "unique_id", Amount: "amount", Date: "date", Currency: "currency"}}),
+	)
+
+	sysData := "id,amount,type,time,currency\ntrx-1,100.00,CREDIT,2025-01-15 10:00:00,USD\n"
+	newForm := func(rates string) *multipart.Form {
+		form := newBankForm(t, "bca.csv", "unique_id,amount,date,currency\nB1,1585000,2025-01-15,IDR\n")
+		if rates != "" {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, err := writer.CreateFormFile("fx_rates", "rates.csv")
+			require.NoError(t, err)
+			part.Write([]byte(rates))
+			writer.Close()
+			upload, err := multipart.NewReader(body, writer.Boundary()).ReadForm(10 << 20)
+			require.NoError(t, err)
+			form.File["fx_rates"] = upload.File["fx_rates"]
+		}
+		return form
+	}
+	opts := ReconcileOptions{
+		SystemProfile: "usd",
+		BankProfiles:  []string{"idr"},
+		FXTolerance:   Tolerance{Percent: 1},
+	}
+
+	t.Run("configured rates", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newForm(""), nil, nil, opts)
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Matched)
+	})
+
+	t.Run("uploaded rates win", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newForm("date,from,to,rate\n2025-01-01,USD,IDR,15850\n"), nil, nil, opts)
+
+		require.NoError(t, err)
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, "15850", result.Matched[0].FX.Rate)
+	})
+
+	t.Run("invalid upload", func(t *testing.T) {
+		_, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newForm("date,from,to,rate\n2025-01-01,USD,IDR,x\n"), nil, nil, opts)
+
+		assert.EqualError(t, err, `invalid fx_rates: fx rate 1: invalid rate "x" (expected positive decimal)`)
+	})
+}
+
+func TestValidateFeeRules(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         []FeeRule
+		errorContains string
+	}{
+		{
+			name:  "valid rules",
+			rules: []FeeRule{{Name: "bca_fee", Bank: "BCA", Amount: 650000}, {Name: "fee_lines", Pattern: `(?i)biaya`}},
+		},
+		{
+			name:          "missing name",
+			rules:         []FeeRule{{Amount: 100}},
+			errorContains: "name is required",
+		},
+		{
+			name:          "reserved name",
+			rules:         []FeeRule{{Name: string(RuleFee), Amount: 100}},
+			errorContains: "reserved",
+		},
+		{
+			name:          "negative fee",
+			rules:         []FeeRule{{Name: "bca_fee", Amount: -100}},
+			errorContains: "must not be negative",
+		},
+		{
+			name:          "no fee",
+			rules:         []FeeRule{{Name: "bca_fee", Bank: "BCA"}},
+			errorContains: "amount, percent or pattern is required",
+		},
+		{
+			name:          "invalid pattern",
+			rules:         []FeeRule{{Name: "bca_fee", Pattern: "("}},
+			errorContains: "invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFeeRules(tt.rules)
+			if tt.errorContains == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+}
+
+func TestReconcileProcessFees(t *testing.T) {
+	day := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	bankDay := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	rules := []FeeRule{
+		{Name: "bca_transfer_fee", Bank: "BCA", Amount: 650000},
+		{Name: "bri_percent_fee", Bank: "BRI", Percent: 0.5},
+		{Name: "fee_lines", Pattern: `(?i)^biaya`},
+	}
+
+	t.Run("fixed and percent fees", func(t *testing.T) {
+		systemTransactions := []SystemTransaction{
+			{TransactionID: "T1", Amount: 1000000, Currency: "IDR", Type: Credit, TransactionTime: day},
+			{TransactionID: "T2", Amount: 1000000, Currency: "IDR", Type: Debit, TransactionTime: day},
+			{TransactionID: "T3", Amount: 200000, Currency: "IDR", Type: Credit, TransactionTime: day},
+		}
+		bankTransactions := []BankTransaction{
+			{BankName: "BCA", UniqueID: "B1", Amount: 993500, Currency: "IDR", Date: bankDay},
+			{BankName: "BCA", UniqueID: "B2", Amount: -1006500, Currency: "IDR", Date: bankDay},
+			{BankName: "BRI", UniqueID: "B3", Amount: 199000, Currency: "IDR", Date: bankDay},
+		}
+
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{FeeRules: rules, Tolerance: Tolerance{Amount: 100}})
+
+		require.Len(t, result.Matched, 3)
+		for i, want := range []struct {
+			bank string
+			rule MatchRule
+			fee  Money
+		}{
+			{"B1", "bca_transfer_fee", 6500},
+			{"B2", "bca_transfer_fee", 6500},
+			{"B3", "bri_percent_fee", 1000},
+		} {
+			assert.Equal(t, want.bank, result.Matched[i].Bank.UniqueID)
+			assert.Equal(t, want.rule, result.Matched[i].Rule)
+			assert.Equal(t, want.fee, result.Matched[i].Fee)
+			assert.Equal(t, 1.0, result.Matched[i].Confidence)
+		}
+		assert.Empty(t, result.Discrepancies)
+		assert.Equal(t, Money(14000), result.TotalFees)
+		assert.Equal(t, []CurrencyTotals{{Currency: "IDR", TotalMatched: 3, TotalFees: 14000}}, result.Currencies)
+
+		data, err := json.Marshal(result.Matched[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"Fee":6500`)
+	})
+
+	t.Run("fee of another bank is a discrepancy", func(t *testing.T) {
+		systemTransactions := []SystemTransaction{
+			{TransactionID: "T1", Amount: 1000000, Currency: "IDR", Type: Credit, TransactionTime: day},
+		}
+		bankTransactions := []BankTransaction{
+			{BankName: "Mandiri", UniqueID: "M1", Amount: 993500, Currency: "IDR", Date: bankDay},
+		}
+
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{FeeRules: rules})
+
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, RuleSameDayDiscrepancy, result.Matched[0].Rule)
+		assert.Zero(t, result.Matched[0].Fee)
+		require.Len(t, result.Discrepancies, 1)
+		assert.Equal(t, Money(6500), result.Discrepancies[0].Difference)
+		assert.Zero(t, result.TotalFees)
+	})
+
+	t.Run("separate fee lines", func(t *testing.T) {
+		systemTransactions := []SystemTransaction{
+			{TransactionID: "T1", Amount: 1000000, Currency: "IDR", Type: Debit, TransactionTime: day},
+		}
+		bankTransactions := []BankTransaction{
+			{BankName: "Mandiri", UniqueID: "M1", Amount: -1000000, Currency: "IDR", Date: bankDay, Description: "TRANSFER OUT"},
+			{BankName: "Mandiri", UniqueID: "M2", Amount: -2500, Currency: "IDR", Date: bankDay, Description: "BIAYA TRANSFER"},
+		}
+
+		result := reconcileProcess(systemTransactions, bankTransactions, ReconcileOptions{FeeRules: rules})
+
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, RuleExactKey, result.Matched[0].Rule)
+		require.Len(t, result.Fees, 1)
+		assert.Equal(t, "M2", result.Fees[0].UniqueID)
+		assert.Empty(t, result.UnmatchedBank)
+		assert.Zero(t, result.TotalUnmatched)
+		assert.Equal(t, Money(2500), result.TotalFees)
+		assert.Equal(t, []CurrencyTotals{{Currency: "IDR", TotalMatched: 1, TotalFees: 2500}}, result.Currencies)
+	})
+
+	t.Run("configured on the service", func(t *testing.T) {
+		service := NewReconciliationService(WithFeeRules(FeeRule{Name: "transfer_fee", Amount: 250}))
+		sysData := "trx_id,amount,type,timestamp\nSYS001,100.00,CREDIT,2025-01-15 10:30:00\n"
+
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newBankForm(t, "bank.csv", "unique_id,amount,date\nB1,97.50,2025-01-15\n"), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, MatchRule("transfer_fee"), result.Matched[0].Rule)
+		assert.Equal(t, Money(250), result.Matched[0].Fee)
+	})
+}
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := LoadTimezone("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTimezone(), loc)
+
+	loc, err = LoadTimezone("UTC")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	_, err = LoadTimezone("Mars/Olympus")
+	assert.EqualError(t, err, `invalid timezone "Mars/Olympus" (expected IANA name such as Asia/Jakarta)`)
+
+	_, offset := time.Date(2025, 1, 15, 0, 0, 0, 0, DefaultTimezone()).Zone()
+	assert.Equal(t, 7*60*60, offset)
+}
+
+func TestReconcileTimezones(t *testing.T) {
+	// 17:30 UTC on the 15th is 00:30 WIB on the 16th
+	sysData := "trx_id,amount,type,timestamp\nSYS001,100.00,CREDIT,2025-01-15 17:30:00\n"
+	bankCSV := "unique_id,amount,date\nB1,100.00,2025-01-16\n"
+
+	t.Run("days bucketed in the filter zone", func(t *testing.T) {
+		service := NewReconciliationService(WithTimezones(Timezones{System: time.UTC}))
+
+		result, err := service.Reconcile("2025-01-16", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankCSV), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		require.Len(t, result.Matched, 1)
+		assert.Equal(t, RuleExactKey, result.Matched[0].Rule)
+		assert.Equal(t, "2025-01-16 00:30:00", result.Matched[0].System.TransactionTime.Format(SystemTimeFormat))
+	})
+
+	t.Run("filter in its own zone", func(t *testing.T) {
+		service := NewReconciliationService(WithTimezones(Timezones{System: time.UTC}))
+
+		result, err := service.Reconcile("2025-01-15", "2025-01-15", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankCSV), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		assert.Empty(t, result.UnmatchedSystem, "transaction falls on the 16th in Jakarta")
+		assert.Empty(t, result.UnmatchedBank)
+	})
+
+	t.Run("all zones UTC", func(t *testing.T) {
+		service := NewReconciliationService(WithTimezones(Timezones{System: time.UTC, Bank: time.UTC, Filter: time.UTC}))
+
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankCSV), nil, nil, ReconcileOptions{})
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Matched)
+		assert.Len(t, result.UnmatchedSystem, 1)
+	})
+
+	t.Run("profile zone wins", func(t *testing.T) {
+		service := NewReconciliationService(WithSystemProfiles(SystemProfile{
+			Name:     "utc_ledger",
+			Columns:  SystemColumns{TransactionID: "0", Amount: "1", Type: "2", TransactionTime: "3"},
+			Location: time.UTC,
+		}))
+
+		result, err := service.Reconcile("2025-01-16", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankCSV), nil, nil, ReconcileOptions{SystemProfile: "utc_ledger"})
+
+		require.NoError(t, err)
+		require.Len(t, result.Matched, 1)
+	})
+}
+
+func TestReconcileStream(t *testing.T) {
+	service := NewReconciliationService()
+	sysData := "trxID,amount,type,transactionTime\n" +
+		"TRX001,100.00,CREDIT,2025-01-15 10:30:00\n" +
+		"TRX002,250.00,DEBIT,2025-01-15 23:10:00\n" +
+		"TRX003,75.00,CREDIT,2025-01-16 08:00:00\n" +
+		"TRX004,40.00,CREDIT,2025-01-17 09:00:00\n" +
+		"TRX005,abc,CREDIT,2025-01-17 09:00:00\n"
+	bankA := "unique_id,amount,date\nBANK001,100.00,2025-01-15\nBANK002,-250.00,2025-01-16\nBANK009,12.00,2025-01-16\n"
+	bankB := "unique_id,amount,date\nBANK003,74.00,2025-01-16\nBANK004,40.00,2025-01-18\n"
+	opts := ReconcileOptions{Window: SettlementWindow{MaxDays: 1}, Tolerance: Tolerance{Amount: 200}}
+
+	runStream := func(t *testing.T, opts ReconcileOptions) (ReconciliationResult, error) {
+		stream, err := service.NewStream("2025-01-15", "2025-01-17", opts)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		require.NoError(t, stream.AddSystemData(strings.NewReader(sysData)))
+		require.NoError(t, stream.AddBankFile("a.csv", strings.NewReader(bankA)))
+		require.NoError(t, stream.AddBankFile("b.csv", strings.NewReader(bankB)))
+		return stream.Finish()
+	}
+
+	t.Run("same result as reconcile", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, file := range [][2]string{{"a.csv", bankA}, {"b.csv", bankB}} {
+			part, err := writer.CreateFormFile("bank_csv", file[0])
+			require.NoError(t, err)
+			_, err = part.Write([]byte(file[1]))
+			require.NoError(t, err)
+		}
+		writer.Close()
+		form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(10 << 20)
+		require.NoError(t, err)
+
+		want, err := service.Reconcile("2025-01-15", "2025-01-17", strings.NewReader(sysData), form, nil, nil, opts)
+		require.NoError(t, err)
+		got, err := runStream(t, opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, got.TotalMatched)
+		assert.Equal(t, want.TotalMatched, got.TotalMatched)
+		assert.Equal(t, want.TotalUnmatched, got.TotalUnmatched)
+		assert.Equal(t, want.TotalDiscrepancies, got.TotalDiscrepancies)
+		assert.Equal(t, want.TotalProcessed, got.TotalProcessed)
+		assert.Equal(t, want.ParseErrors, got.ParseErrors)
+		assert.Equal(t, want.Currencies, got.Currencies)
+		require.Len(t, got.UnmatchedBank["Stmt-a.csv"], 1)
+		assert.Equal(t, "BANK009", got.UnmatchedBank["Stmt-a.csv"][0].UniqueID)
+	})
+
+	t.Run("window bounds matching", func(t *testing.T) {
+		result, err := runStream(t, ReconcileOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Len(t, result.UnmatchedSystem, 2)
+	})
+
+	t.Run("strict mode", func(t *testing.T) {
+		_, err := runStream(t, ReconcileOptions{Strict: true})
+
+		var strictErr *StrictModeError
+		assert.ErrorAs(t, err, &strictErr)
+	})
+
+	t.Run("close removes spool", func(t *testing.T) {
+		stream, err := service.NewStream("2025-01-15", "2025-01-17", opts)
+		require.NoError(t, err)
+		require.NoError(t, stream.AddSystemData(strings.NewReader(sysData)))
+		dir := stream.spool.dir
+		assert.DirExists(t, dir)
+
+		require.NoError(t, stream.Close())
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := service.NewStream("15-01-2025", "2025-01-17", opts)
+		assert.ErrorContains(t, err, "invalid start_date")
+	})
+
+	t.Run("matches do not span system days or leave the window", func(t *testing.T) {
+		sysData := "trxID,amount,type,transactionTime\n" +
+			"TRX001,100.00,CREDIT,2025-01-15 10:00:00\n" +
+			"TRX002,50.00,CREDIT,2025-01-16 10:00:00\n" +
+			"TRX003,80.00,CREDIT,2025-01-15 11:00:00\n"
+		bankCSV := "unique_id,amount,date,remark\n" +
+			"BANK001,150.00,2025-01-16,\n" +
+			"BANK003,80.00,2025-01-20,TRX003\n"
+		opts := ReconcileOptions{
+			Window:       SettlementWindow{MaxDays: 1},
+			Tolerance:    Tolerance{Amount: 1},
+			MaxGroupSize: 2,
+			Reference:    ReferenceRule{Column: "remark"},
+		}
+
+		want, err := service.Reconcile("2025-01-15", "2025-01-20", strings.NewReader(sysData), newBankForm(t, "bank.csv", bankCSV), nil, nil, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, want.TotalMatched)
+		assert.Equal(t, 1, want.TotalGroupMatched)
+
+		stream, err := service.NewStream("2025-01-15", "2025-01-20", opts)
+		require.NoError(t, err)
+		defer stream.Close()
+		require.NoError(t, stream.AddSystemData(strings.NewReader(sysData)))
+		require.NoError(t, stream.AddBankFile("bank.csv", strings.NewReader(bankCSV)))
+		got, err := stream.Finish()
+		require.NoError(t, err)
+
+		assert.Zero(t, got.TotalMatched)
+		assert.Zero(t, got.TotalGroupMatched)
+		assert.Len(t, got.UnmatchedSystem, 3)
+	})
+
+	t.Run("spool keeps few files open", func(t *testing.T) {
+		stream, err := service.NewStream("2025-01-01", "2025-01-31", ReconcileOptions{})
+		require.NoError(t, err)
+		defer stream.Close()
+		stream.spool.maxOpen = 2
+
+		var sysData, bankCSV strings.Builder
+		sysData.WriteString("trxID,amount,type,transactionTime\n")
+		bankCSV.WriteString("unique_id,amount,date\n")
+		// alternate days so every file is closed and reopened
+		for i := range 20 {
+			day := 1 + i%5
+			fmt.Fprintf(&sysData, "TRX%03d,%d.00,CREDIT,2025-01-%02d 10:00:00\n", i, 10+i, day)
+			fmt.Fprintf(&bankCSV, "BANK%03d,%d.00,2025-01-%02d\n", i, 10+i, day)
+		}
+		require.NoError(t, stream.AddSystemData(strings.NewReader(sysData.String())))
+		require.NoError(t, stream.AddBankFile("bank.csv", strings.NewReader(bankCSV.String())))
+
+		assert.Len(t, stream.spool.files, 2)
+		assert.Equal(t, 4, stream.spool.segments["system-2025-01-01"])
+
+		result, err := stream.Finish()
+		require.NoError(t, err)
+		assert.Equal(t, 20, result.TotalMatched)
+		assert.Zero(t, result.TotalUnmatched)
+	})
+}
+
+func TestDayBlocks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 10, 0, 0, 0, time.UTC) }
+	system := []SystemTransaction{
+		{TransactionID: "SYS001", TransactionTime: day(13)},
+		{TransactionID: "SYS002", TransactionTime: day(15)},
+		{TransactionID: "SYS003", TransactionTime: day(14)},
+	}
+	bank := []BankTransaction{
+		{UniqueID: "BANK001", Date: day(16)},
+		{UniqueID: "BANK002", Date: day(13)},
+		{UniqueID: "BANK003", Date: day(20)},
+	}
+	ids := func(blocks []dayBlock) (out [][]string) {
+		for _, b := range blocks {
+			var block []string
+			for _, s := range b.system {
+				block = append(block, s.TransactionID)
+			}
+			for _, b := range b.bank {
+				block = append(block, b.UniqueID)
+			}
+			out = append(out, block)
+		}
+		return out
+	}
+
+	t.Run("same day", func(t *testing.T) {
+		blocks := dayBlocks(system, bank, []SettlementWindow{{}})
+		assert.Equal(t, [][]string{{"SYS001", "BANK002"}, {"SYS003"}, {"SYS002"}, {"BANK001"}, {"BANK003"}}, ids(blocks))
+	})
+
+	t.Run("window joins days", func(t *testing.T) {
+		blocks := dayBlocks(system, bank, []SettlementWindow{{}, {MaxDays: 1}})
+		assert.Equal(t, [][]string{{"SYS001", "SYS002", "SYS003", "BANK001", "BANK002"}, {"BANK003"}}, ids(blocks))
+	})
+}
+
+func TestReconcileProcessWorkers(t *testing.T) {
+	system, bank := generateTransactions(20, 30)
+
+	for _, opts := range []ReconcileOptions{
+		{Tolerance: Tolerance{Amount: 500}},
+		{Tolerance: Tolerance{Amount: 500}, Window: SettlementWindow{MaxDays: 2, SkipWeekends: true}, MaxGroupSize: 3},
+		{Tolerance: Tolerance{Amount: 500}, Mode: MatchModeOptimal},
+	} {
+		sequential := reconcileProcess(system, bank, opts)
+		opts.Workers = 4
+		parallel := reconcileProcess(system, bank, opts)
+
+		assert.Equal(t, sequential.TotalMatched, parallel.TotalMatched)
+		assert.Equal(t, sequential.TotalGroupMatched, parallel.TotalGroupMatched)
+		assert.Equal(t, sequential.TotalDiscrepancies, parallel.TotalDiscrepancies)
+		assert.Equal(t, sequential.UnmatchedSystem, parallel.UnmatchedSystem)
+		assert.Equal(t, sequential.UnmatchedBank, parallel.UnmatchedBank)
+		assert.ElementsMatch(t, sequential.Matched, parallel.Matched)
+		assert.Equal(t, parallel, reconcileProcess(system, bank, opts), "parallel result must be deterministic")
+	}
+
+	t.Run("custom matchers run sequentially", func(t *testing.T) {
+		_, ok := matcherWindows([]Matcher{ExactKeyMatcher(), idMatcher{}}, SettlementWindow{})
+		assert.False(t, ok)
+	})
+}
+
+// generateTransactions returns perDay system transactions a day for days
+// days, with bank lines matching them exactly, off by an amount, split in
+// two or missing.
+func generateTransactions(days, perDay int) ([]SystemTransaction, []BankTransaction) {
+	var system []SystemTransaction
+	var bank []BankTransaction
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	for d := 0; d < days; d++ {
+		for i := 0; i < perDay; i++ {
+			n := d*perDay + i
+			at := start.AddDate(0, 0, d).Add(time.Duration(i) * time.Minute)
+			sys := SystemTransaction{TransactionID: fmt.Sprintf("SYS%06d", n), Amount: Money(10000 + (n%97)*100), Type: Credit, TransactionTime: at}
+			system = append(system, sys)
+
+			posted := dayOf(at).AddDate(0, 0, n%3)
+			id := fmt.Sprintf("BANK%06d", n)
+			switch n % 5 {
+			case 0, 1:
+				bank = append(bank, BankTransaction{BankName: "Bank A", UniqueID: id, Amount: sys.Amount, Date: posted})
+			case 2:
+				bank = append(bank, BankTransaction{BankName: "Bank A", UniqueID: id, Amount: sys.Amount - 300, Date: posted})
+			case 3:
+				bank = append(bank,
+					BankTransaction{BankName: "Bank B", UniqueID: id + "a", Amount: sys.Amount / 2, Date: posted},
+					BankTransaction{BankName: "Bank B", UniqueID: id + "b", Amount: sys.Amount - sys.Amount/2, Date: posted})
+			}
+		}
+	}
+	return system, bank
+}
+
+// BenchmarkReconcileProcess matches 60 independent days with 1 to 8 workers.
+// With at least as many CPUs as workers, the time per run is expected to fall
+// close to linearly with the workers, since no day waits on another; beyond
+// GOMAXPROCS extra workers gain nothing, so run it with -cpu 8 or more.
+func BenchmarkReconcileProcess(b *testing.B) {
+	system, bank := generateTransactions(60, 200)
+
+	for _, mode := range []MatchMode{MatchModeGreedy, MatchModeOptimal} {
+		for _, workers := range []int{1, 2, 4, 8} {
+			opts := ReconcileOptions{Tolerance: Tolerance{Amount: 500}, MaxGroupSize: 2, Mode: mode, Workers: workers}
+			b.Run(fmt.Sprintf("%s/workers=%d", mode, workers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					reconcileProcess(system, bank, opts)
+				}
+			})
+		}
+	}
+}
+
+const sampleMT940 = `{1:F01BANKIDJAXXXX0000000000}{2:I940BANKIDJAXXXXN}{4:
+:20:STMT250115
+:25:BANKIDJA/1234567890
+:28C:00015/001
+:60F:C250114EUR1000000,00
+:61:2501150115C100,50NTRFTRX001//BK0001
+:86:Transfer from customer
+ invoice 2025-001
+:61:250116D250,NCHKNONREF//BK0002
+:61:250116RD40,NTRFNONREF
+:86:Reversal
+:61:250115C1x,00NTRF
+:61:250120C5,00NTRFTRX099//BK0099
+:62F:C250116EUR999890,50
+-}
+`
+
+func TestLoadMT940Statement(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	t.Run("statement lines and balances", func(t *testing.T) {
+		trxs, statements, parseErrors, err := LoadMT940Statement(strings.NewReader(sampleMT940), "stmt.sta", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []BankTransaction{
+			{BankName: "BANK", UniqueID: "BK0001", Amount: 10050, Currency: "EUR", Date: day(15), Description: "Transfer from customer invoice 2025-001", Reference: "TRX001"},
+			{BankName: "BANK", UniqueID: "BK0002", Amount: -25000, Currency: "EUR", Date: day(16)},
+			{BankName: "BANK", UniqueID: "stmt.sta:10", Amount: 4000, Currency: "EUR", Date: day(16), Description: "Reversal"},
+		}, trxs)
+		assert.Equal(t, []Statement{{
+			Reference:      "STMT250115",
+			Account:        "BANKIDJA/1234567890",
+			Number:         "00015/001",
+			OpeningBalance: &StatementBalance{Date: day(14), Currency: "EUR", Amount: 100000000},
+			ClosingBalance: &StatementBalance{Date: day(16), Currency: "EUR", Amount: 99989050},
+		}}, statements)
+		assert.Equal(t, []ParseError{{File: "stmt.sta", Line: 12, Column: ":61:", Value: "250115C1x,00NTRF", Reason: "invalid statement line (expected value date, mark, amount and transaction type)"}}, parseErrors)
+	})
+
+	t.Run("reference rule reads the information field", func(t *testing.T) {
+		data := ":20:S1\n:60F:C250114USD0,\n:61:250115C10,NTRFNONREF//B1\n:86:PAYMENT TRX-777 THANKS\n"
+		rule := ReferenceRule{Column: "description", Pattern: `TRX-(\d+)`}
+		trxs, _, _, err := LoadMT940Statement(strings.NewReader(data), "stmt.sta", BankProfile{}, "BANK", start, end, rule)
+
+		require.NoError(t, err)
+		require.Len(t, trxs, 1)
+		assert.Equal(t, "777", trxs[0].Reference)
+		assert.Equal(t, Money(1000), trxs[0].Amount)
+		assert.Equal(t, "USD", trxs[0].Currency)
+	})
+
+	t.Run("profile currency without balance", func(t *testing.T) {
+		data := ":20:S1\n:61:250115C10,NTRFREF1\n"
+		trxs, _, _, err := LoadMT940Statement(strings.NewReader(data), "stmt.sta", BankProfile{Currency: "jpy"}, "BANK", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		require.Len(t, trxs, 1)
+		assert.Equal(t, BankTransaction{BankName: "BANK", UniqueID: "REF1", Amount: 10, Currency: "JPY", Date: day(15), Reference: "REF1"}, trxs[0])
+	})
+
+	t.Run("no statement", func(t *testing.T) {
+		_, _, _, err := LoadMT940Statement(strings.NewReader("-}\n"), "stmt.sta", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		assert.EqualError(t, err, "no MT940 statement found")
+	})
+}
+
+func TestParseBankFormat(t *testing.T) {
+	for input, want := range map[string]BankFormat{"": BankFormatAuto, "CSV": BankFormatCSV, " mt940 ": BankFormatMT940, "OFX": BankFormatOFX, "bai2": BankFormatBAI2, "auto": BankFormatAuto} {
+		got, err := ParseBankFormat(input)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseBankFormat("pdf")
+	assert.EqualError(t, err, `invalid bank_format "pdf" (expected one of csv, mt940, camt, ofx, bai2, xlsx, auto)`)
+}
+
+func TestReconcileMT940(t *testing.T) {
+	service := NewReconciliationService()
+	sysData := "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\nTRX002,250.00,DEBIT,2025-01-16 09:00:00\n"
+
+	for _, opts := range []ReconcileOptions{{Currency: "EUR"}, {Currency: "EUR", BankFormats: []BankFormat{"MT940"}}} {
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "stmt.sta", sampleMT940), nil, nil, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.TotalMatched)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, BankFormatMT940, result.Files[0].Format)
+		assert.Equal(t, 3, result.Files[0].Transactions)
+		require.Len(t, result.Files[0].Statements, 1)
+		assert.Equal(t, Money(99989050), result.Files[0].Statements[0].ClosingBalance.Amount)
+		assert.Len(t, result.ParseErrors, 1)
+	}
+
+	t.Run("csv format forced", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "stmt.sta", sampleMT940), nil, nil, ReconcileOptions{Currency: "EUR", BankFormats: []BankFormat{BankFormatCSV}})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.TotalMatched)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "stmt.sta", sampleMT940), nil, nil, ReconcileOptions{BankFormats: []BankFormat{"pdf"}})
+		assert.ErrorContains(t, err, "invalid bank_format")
+	})
+}
+
+const sampleCAMT053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT-0115</Id>
+      <ElctrncSeqNb>15</ElctrncSeqNb>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-14</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">850.50</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-16</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <Amt Ccy="EUR">100.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-01-15</Dt></BookgDt><ValDt><Dt>2025-01-16</Dt></ValDt>
+        <AcctSvcrRef>BK0001</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>TRX001</EndToEndId></Refs>
+          <RmtInf><Ustrd>Invoice 2025-001</Ustrd><Ustrd>thank you</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">250.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2025-01-15T20:30:00Z</DtTm></BookgDt>
+        <NtryDtls><TxDtls><Refs><EndToEndId>NOTPROVIDED</EndToEndId><AcctSvcrRef>BK0002</AcctSvcrRef></Refs></TxDtls></NtryDtls>
+        <AddtlNtryInf>Supplier payment</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">5.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><RvslInd>true</RvslInd><Sts>BOOK</Sts>
+        <ValDt><Dt>2025-01-16</Dt></ValDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">9.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>PDNG</Sts>
+        <BookgDt><Dt>2025-01-16</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">abc</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+        <BookgDt><Dt>2025-01-16</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+func TestLoadCAMTStatement(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	day := func(d int, loc *time.Location) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, loc) }
+
+	t.Run("camt.053 statement", func(t *testing.T) {
+		trxs, statements, parseErrors, err := LoadCAMTStatement(strings.NewReader(sampleCAMT053), "stmt.xml", BankProfile{Location: jakarta}, "BANK", start, end, ReferenceRule{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []BankTransaction{
+			{BankName: "BANK", UniqueID: "BK0001", Amount: 10000, Currency: "EUR", Date: day(15, jakarta), Description: "Invoice 2025-001 thank you", Reference: "TRX001"},
+			{BankName: "BANK", UniqueID: "BK0002", Amount: -25000, Currency: "EUR", Date: day(16, jakarta), Description: "Supplier payment"},
+			{BankName: "BANK", UniqueID: "stmt.xml:32", Amount: 500, Currency: "EUR", Date: day(16, jakarta)},
+		}, trxs)
+		assert.Equal(t, []Statement{{
+			Reference:      "STMT-0115",
+			Account:        "DE89370400440532013000",
+			Number:         "15",
+			OpeningBalance: &StatementBalance{Date: day(14, jakarta), Currency: "EUR", Amount: 100000},
+			ClosingBalance: &StatementBalance{Date: day(16, jakarta), Currency: "EUR", Amount: 85050},
+		}}, statements)
+		assert.Equal(t, []ParseError{{File: "stmt.xml", Line: 40, Column: "Ntry", Value: "abc", Reason: "invalid amount"}}, parseErrors)
+	})
+
+	t.Run("camt.054 notification", func(t *testing.T) {
+		data := `<Document><BkToCstmrDbtCdtNtfctn><Ntfctn><Id>N1</Id>
+<Acct><Id><Othr><Id>1234567890</Id></Othr></Id></Acct>
+<Ntry><NtryRef>E1</NtryRef><Amt Ccy="JPY">1500</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><Dt>2025-01-15</Dt></BookgDt>
+<NtryDtls><TxDtls><Refs><EndToEndId>PAY-42</EndToEndId></Refs></TxDtls></NtryDtls></Ntry>
+</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`
+		rule := ReferenceRule{Column: "reference", Pattern: `PAY-(\d+)`}
+		trxs, statements, _, err := LoadCAMTStatement(strings.NewReader(data), "note.xml", BankProfile{}, "BANK", start, end, rule)
+
+		require.NoError(t, err)
+		assert.Equal(t, []BankTransaction{{BankName: "BANK", UniqueID: "E1", Amount: 1500, Currency: "JPY", Date: day(15, time.UTC), Reference: "42"}}, trxs)
+		assert.Equal(t, []Statement{{Reference: "N1", Account: "1234567890"}}, statements)
+	})
+
+	t.Run("not a camt document", func(t *testing.T) {
+		_, _, _, err := LoadCAMTStatement(strings.NewReader("<Document></Document>"), "stmt.xml", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		assert.EqualError(t, err, "no camt.053 statement or camt.054 notification found")
+
+		_, _, _, err = LoadCAMTStatement(strings.NewReader("<Document><Stmt>"), "stmt.xml", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		assert.ErrorContains(t, err, "invalid XML")
+	})
+}
+
+func TestReconcileCAMT(t *testing.T) {
+	service := NewReconciliationService()
+	// TRX001 is matched by its end-to-end ID despite the amount difference
+	sysData := "trxID,amount,type,transactionTime\nTRX001,99.00,CREDIT,2025-01-15 10:30:00\nTRX002,250.00,DEBIT,2025-01-16 09:00:00\n"
+
+	result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "stmt.xml", sampleCAMT053), nil, nil, ReconcileOptions{Currency: "EUR"})
+
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, BankFormatCAMT, result.Files[0].Format)
+	assert.Equal(t, 3, result.Files[0].Transactions)
+	require.Len(t, result.Matched, 2)
+	assert.Equal(t, RuleReference, result.Matched[0].Rule)
+	assert.Equal(t, "TRX001", result.Matched[0].System.TransactionID)
+	assert.Equal(t, "TRX002", result.Matched[1].System.TransactionID)
+}
+
+const sampleOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+CHARSET:1252
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1
+<STMTRS><CURDEF>USD
+<BANKACCTFROM><BANKID>123<ACCTID>987654<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20250115<DTEND>20250116
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250115103000.000[+7:WIB]<TRNAMT>100.50<FITID>F001<NAME>ACME &amp; Co<MEMO>Invoice TRX001</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250115200000[-5:EST]<TRNAMT>-250.00<FITID>F002<REFNUM>TRX002<NAME>Supplier</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250116<TRNAMT>12,00<FITID>F003<CURRENCY><CURRATE>0.9<CURSYM>EUR</CURRENCY></STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250116<TRNAMT>abc<FITID>F004</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250120<TRNAMT>1.00<FITID>F005</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>850.50<DTASOF>20250116</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestLoadOFXStatement(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	day := func(d int, loc *time.Location) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, loc) }
+
+	t.Run("SGML statement", func(t *testing.T) {
+		trxs, statements, parseErrors, err := LoadOFXStatement(strings.NewReader(sampleOFX), "stmt.ofx", BankProfile{Location: jakarta}, "BANK", start, end, ReferenceRule{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []BankTransaction{
+			{BankName: "BANK", UniqueID: "F001", Amount: 10050, Currency: "USD", Date: day(15, jakarta), Description: "ACME & Co Invoice TRX001"},
+			{BankName: "BANK", UniqueID: "F002", Amount: -25000, Currency: "USD", Date: day(16, jakarta), Description: "Supplier"},
+			{BankName: "BANK", UniqueID: "F003", Amount: 1200, Currency: "EUR", Date: day(16, jakarta)},
+		}, trxs)
+		assert.Equal(t, []Statement{{
+			Account:        "987654",
+			ClosingBalance: &StatementBalance{Date: day(16, jakarta), Currency: "USD", Amount: 85050},
+		}}, statements)
+		assert.Equal(t, []ParseError{{File: "stmt.ofx", Line: 14, Column: "TRNAMT", Value: "abc", Reason: "invalid amount"}}, parseErrors)
+	})
+
+	t.Run("XML credit card statement", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS><CURDEF>USD</CURDEF><CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250115</DTPOSTED><TRNAMT>-42.10</TRNAMT><FITID>C1</FITID><NAME>Shop</NAME><MEMO>PAY-42</MEMO></STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>2025-01-15</DTPOSTED><TRNAMT>-1.00</TRNAMT><FITID>C2</FITID></STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250115</DTPOSTED><TRNAMT>-1.00</TRNAMT></STMTTRN>
+</BANKTRANLIST></CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>`
+		rule := ReferenceRule{Column: "description", Pattern: `PAY-(\d+)`}
+		trxs, statements, parseErrors, err := LoadOFXStatement(strings.NewReader(data), "card.qfx", BankProfile{}, "BANK", start, end, rule)
+
+		require.NoError(t, err)
+		assert.Equal(t, []BankTransaction{{BankName: "BANK", UniqueID: "C1", Amount: -4210, Currency: "USD", Date: day(15, time.UTC), Description: "Shop PAY-42", Reference: "42"}}, trxs)
+		assert.Equal(t, []Statement{{Account: "4111"}}, statements)
+		assert.Equal(t, []ParseError{
+			{File: "card.qfx", Line: 6, Column: "DTPOSTED", Value: "2025-01-15", Reason: "invalid date (expected YYYYMMDDHHMMSS)"},
+			{File: "card.qfx", Line: 7, Column: "FITID", Reason: "missing value"},
+		}, parseErrors)
+	})
+
+	t.Run("no statement", func(t *testing.T) {
+		_, _, _, err := LoadOFXStatement(strings.NewReader("OFXHEADER:100\n\n<OFX></OFX>"), "stmt.ofx", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		assert.EqualError(t, err, "no OFX statement found")
+	})
+}
+
+const sampleBAI2 = `01,BANKUS,CUSTCO,250116,0800,1,,,2/
+02,CUSTCO,BANKUS,1,250115,,USD,2/
+03,0001234567,,010,100000,,,015,85050,,/
+16,195,10050,0,BR001,TRX001,Incoming wire
+88,ACME Co invoice 7
+16,475,25000,V,250116,,BR002,,Check paid
+16,890,,,,,Information only
+16,195,abc,0,BR003,,/
+49,185050,6/
+98,185050,1,8/
+02,CUSTCO,BANKUS,1,250120,,USD,2/
+03,0001234567,,/
+16,195,100,0,BR009,,/
+49,100,2/
+98,100,1,4/
+99,185150,2,15/
+`
+
+func TestLoadBAI2Statement(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	t.Run("balance report", func(t *testing.T) {
+		trxs, statements, parseErrors, err := LoadBAI2Statement(strings.NewReader(sampleBAI2), "report.bai", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []BankTransaction{
+			{BankName: "BANK", UniqueID: "BR001", Amount: 10050, Currency: "USD", Date: day(15), Description: "Incoming wire ACME Co invoice 7", Reference: "TRX001"},
+			{BankName: "BANK", UniqueID: "BR002", Amount: -25000, Currency: "USD", Date: day(15), Description: "Check paid"},
+		}, trxs)
+		assert.Equal(t, []Statement{
+			{
+				Account:        "0001234567",
+				OpeningBalance: &StatementBalance{Date: day(15), Currency: "USD", Amount: 100000},
+				ClosingBalance: &StatementBalance{Date: day(15), Currency: "USD", Amount: 85050},
+			},
+			{Account: "0001234567"},
+		}, statements)
+		assert.Equal(t, []ParseError{{File: "report.bai", Line: 8, Column: "16", Value: "195,abc,0,BR003,,", Reason: "invalid amount"}}, parseErrors)
+	})
+
+	t.Run("account currency and type codes", func(t *testing.T) {
+		data := "01,B,C,250116,0800,1,,,2/\n02,C,B,1,250115,,USD,2/\n03,99,JPY,/\n16,301,1500,0,,INV-9,/\n16,750,1,0,X,,/\n49,1500,3/\n"
+		trxs, _, parseErrors, err := LoadBAI2Statement(strings.NewReader(data), "report.bai", BankProfile{}, "BANK", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		assert.Equal(t, []BankTransaction{{BankName: "BANK", UniqueID: "INV-9", Amount: 1500, Currency: "JPY", Date: day(15), Reference: "INV-9"}}, trxs)
+		assert.Equal(t, []ParseError{{File: "report.bai", Line: 5, Column: "16", Value: "750,1,0,X,,", Reason: "invalid type code (expected credit 100-399 or debit 400-699)"}}, parseErrors)
+	})
+
+	t.Run("detail continued before its text", func(t *testing.T) {
+		data := "01,B,C,250116,0800,1,,,2/\n02,C,B,1,250115,,USD,2/\n03,99,,/\n" +
+			"16,195,10050,0,BR001/\n88,TRX001,Wire from\n88,ACME Co\n" +
+			"16,475,25000,V,250116/\n88,,BR002,,Check paid\n49,0,7/\n"
+		trxs, _, parseErrors, err := LoadBAI2Statement(strings.NewReader(data), "report.bai", BankProfile{}, "BANK", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		assert.Empty(t, parseErrors)
+		assert.Equal(t, []BankTransaction{
+			{BankName: "BANK", UniqueID: "BR001", Amount: 10050, Currency: "USD", Date: day(15), Description: "Wire from ACME Co", Reference: "TRX001"},
+			{BankName: "BANK", UniqueID: "BR002", Amount: -25000, Currency: "USD", Date: day(15), Description: "Check paid"},
+		}, trxs)
+	})
+
+	t.Run("text ending in a slash", func(t *testing.T) {
+		data := "01,B,C,250116,0800,1,,,2/\n02,C,B,1,250115,,USD,2/\n03,99,,/\n" +
+			"16,195,10050,0,BR001,,Paid 1/\n16,195,200,0,BR002,,Wire A/\n88,B/\n16,195,300,0,BR003,,/\n49,10550,6/\n"
+		trxs, _, parseErrors, err := LoadBAI2Statement(strings.NewReader(data), "report.bai", BankProfile{}, "BANK", start, end, ReferenceRule{})
+
+		require.NoError(t, err)
+		assert.Empty(t, parseErrors)
+		require.Len(t, trxs, 3)
+		assert.Equal(t, "Paid 1/", trxs[0].Description)
+		assert.Equal(t, "Wire A/ B/", trxs[1].Description)
+		assert.Empty(t, trxs[2].Description)
+	})
+
+	t.Run("no account", func(t *testing.T) {
+		_, _, _, err := LoadBAI2Statement(strings.NewReader("01,B,C,250116,0800,1,,,2/\n99,0,0,1/\n"), "report.bai", BankProfile{}, "BANK", start, end, ReferenceRule{})
+		assert.EqualError(t, err, "no BAI2 account found")
+	})
+}
+
+func TestDetectBankFormat(t *testing.T) {
+	for head, want := range map[string]BankFormat{
+		"Date,Description,Amount\n":                            BankFormatCSV,
+		"{1:F01BANKDEFFXXXX0000000000}{2:I940}{4:\n:20:STMT\n": BankFormatMT940,
+		"\ufeff:20:STMT\n":                                     BankFormatMT940,
+		sampleCAMT053:                                          BankFormatCAMT,
+		sampleOFX:                                              BankFormatOFX,
+		"<?xml version=\"1.0\"?>\n<?OFX OFXHEADER=\"200\"?>\n": BankFormatOFX,
+		sampleBAI2: BankFormatBAI2,
+	} {
+		got, r := sniffBankFormat(strings.NewReader(head))
+		assert.Equal(t, want, got)
+
+		// the sniffed bytes are read again
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, head, string(data))
+	}
+}
+
+func TestReconcileOFXAndBAI2(t *testing.T) {
+	service := NewReconciliationService()
+
+	for _, tc := range []struct {
+		file, data, sysData string
+		format              BankFormat
+	}{
+		{"stmt.ofx", sampleOFX, "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\nTRX002,250.00,DEBIT,2025-01-16 09:00:00\n", BankFormatOFX},
+		{"report.bai", sampleBAI2, "trxID,amount,type,transactionTime\nTRX001,100.50,CREDIT,2025-01-15 10:30:00\nTRX002,250.00,DEBIT,2025-01-15 09:00:00\n", BankFormatBAI2},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(tc.sysData), newBankForm(t, tc.file, tc.data), nil, nil, ReconcileOptions{Currency: "USD"})
+
+			require.NoError(t, err)
+			require.Len(t, result.Files, 1)
+			assert.Equal(t, tc.format, result.Files[0].Format)
+			assert.Equal(t, 2, result.TotalMatched)
+			assert.Len(t, result.ParseErrors, 1)
+		})
+	}
+}
+
+// newXLSX builds a workbook with the given name and sheetData pairs. Shared
+// strings 0 to 5 are trxID, amount, type, transactionTime, CREDIT and DEBIT;
+// style 1 is a date, 2 a date time and 3 a number with a quoted suffix.
+func newXLSX(t *testing.T, sheets ...[2]string) []byte {
+	var sheetList, rels strings.Builder
+	files := map[string]string{
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>trxID</t></si><si><t>amount</t></si><si><t>type</t></si><si><t>transactionTime</t></si><si><t>CREDIT</t></si><si><t>DEBIT</t></si></sst>`,
+		"xl/styles.xml": `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts><numFmt numFmtId="164" formatCode="dd/mm/yyyy hh:mm:ss"/><numFmt numFmtId="165" formatCode="0.00&quot; d&quot;"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+	}
+	for i, sheet := range sheets {
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, sheet[0], i+1, i+1)
+		// the last sheet has an absolute target, as some writers produce
+		target := fmt.Sprintf("worksheets/sheet%d.xml", i+1)
+		if i == len(sheets)-1 {
+			target = "/xl/" + target
+		}
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Target="%s"/>`, i+1, target)
+		files[fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)] = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet[1] + `</sheetData></worksheet>`
+	}
+	files["xl/workbook.xml"] = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheetList.String() + `</sheets></workbook>`
+	files["xl/_rels/workbook.xml.rels"] = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`
+
+	body := &bytes.Buffer{}
+	zw := zip.NewWriter(body)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return body.Bytes()
+}
+
+func TestXLSXReader(t *testing.T) {
+	workbook := newXLSX(t,
+		[2]string{"Summary", `<row r="1"><c r="A1" t="inlineStr"><is><t>nothing here</t></is></c></row>`},
+		[2]string{"Transactions", `<row r="1"><c r="A1" t="inlineStr"><is><t>Statement January</t></is></c></row>
+<row r="3"><c r="A3" t="s"><v>0</v></c><c r="B3" t="s"><v>1</v></c><c r="D3" t="s"><v>3</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><r><t>TRX</t></r><r><t>001</t></r></is></c><c r="B4"><v>100.50000000000001</v></c><c r="C4" t="b"><v>1</v></c><c r="D4" s="2"><v>45672.4375</v></c></row>
+<row r="5"><c r="A5" s="1"/></row>
+<row r="6"><c r="B6" s="3"><v>0.30000000000000004</v></c><c r="C6"><v>1234.5699999999999</v></c><c r="D6" s="1"><v>45673</v></c></row>
+<row r="7"><c r="A7"><v>1234567890123456789</v></c><c r="B7"><v>1.2345678901234567E+18</v></c><c r="C7"><v>-2.5E-3</v></c><c r="D7"><v>123456789012.345</v></c></row>`},
+	)
+
+	t.Run("selected sheet from the header row", func(t *testing.T) {
+		x, err := newXLSXReader(bytes.NewReader(workbook), " transactions", 3)
+		require.NoError(t, err)
+		x.DateLayout, x.DecimalSeparator = SystemTimeFormat, ","
+
+		assert.Equal(t, []string{"trxID", "amount", "", "transactionTime"}, x.header())
+
+		var rows [][]string
+		var lines []int
+		for {
+			record, err := x.Read()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			line, _ := x.FieldPos(0)
+			rows, lines = append(rows, record), append(lines, line)
+		}
+		assert.Equal(t, [][]string{
+			{"trxID", "amount", "", "transactionTime"},
+			{"TRX001", "100,5", "TRUE", "2025-01-15 10:30:00"},
+			{"", "0,3", "1234,57", "2025-01-16 00:00:00"},
+			{"1234567890123460000", "1234567890123460000", "-0,0025", "123456789012,345"},
+		}, rows)
+		assert.Equal(t, []int{3, 4, 6, 7}, lines)
+	})
+
+	t.Run("first sheet", func(t *testing.T) {
+		x, err := newXLSXReader(bytes.NewReader(workbook), "", 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"nothing here"}, x.header())
+	})
+
+	t.Run("unknown sheet", func(t *testing.T) {
+		_, err := newXLSXReader(bytes.NewReader(workbook), "Missing", 0)
+		assert.EqualError(t, err, `sheet "Missing" not found (expected one of Summary, Transactions)`)
+	})
+
+	t.Run("not a workbook", func(t *testing.T) {
+		_, err := newXLSXReader(strings.NewReader("trxID,amount\n"), "", 0)
+		assert.ErrorContains(t, err, "invalid XLSX file")
+	})
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := []struct {
+		id   int
+		code string
+		want bool
+	}{
+		{14, "", true},
+		{22, "", true},
+		{0, "", false},
+		{4, "", false},
+		{164, "dd/mm/yyyy", true},
+		{165, `0.00" d"`, false},
+		{166, "[Red]#,##0.00", false},
+		{167, "[$-409]mmm d, yyyy", true},
+		{168, `0\d`, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isDateFormat(tt.id, tt.code), tt.code)
+	}
+}
+
+func TestReconcileXLSX(t *testing.T) {
+	service := NewReconciliationService()
+	system := newXLSX(t, [2]string{"Sheet1", `<row r="1"><c r="A1" t="inlineStr"><is><t>Orders</t></is></c></row>
+<row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" t="s"><v>1</v></c><c r="C2" t="s"><v>2</v></c><c r="D2" t="s"><v>3</v></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><t>TRX001</t></is></c><c r="B3"><v>100.5</v></c><c r="C3" t="s"><v>4</v></c><c r="D3" s="2"><v>45672.4375</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>TRX002</t></is></c><c r="B4"><v>250</v></c><c r="C4" t="s"><v>5</v></c><c r="D4" s="2"><v>45673.375</v></c></row>
+<row r="5"><c r="A5" t="inlineStr"><is><t>TRX003</t></is></c><c r="B5" t="inlineStr"><is><t>n/a</t></is></c><c r="C5" t="s"><v>4</v></c><c r="D5" s="2"><v>45673.5</v></c></row>`})
+	bank := newXLSX(t, [2]string{"Statement", `<row r="1"><c r="A1" t="inlineStr"><is><t>Bank statement</t></is></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>unique_identifier</t></is></c><c r="B2" t="inlineStr"><is><t>amount</t></is></c><c r="C2" t="inlineStr"><is><t>date</t></is></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><t>BK1</t></is></c><c r="B3"><v>100.5</v></c><c r="C3" s="1"><v>45672</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>BK2</t></is></c><c r="B4"><v>-250</v></c><c r="C4" s="1"><v>45673</v></c></row>`})
+
+	t.Run("header row", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", bytes.NewReader(system), newBankForm(t, "bank.xlsx", string(bank)), nil, nil, ReconcileOptions{Currency: "USD", HeaderRow: 2})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.TotalMatched)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, BankFormatXLSX, result.Files[0].Format)
+		assert.Equal(t, DefaultProfileName, result.Files[0].Profile)
+		assert.Equal(t, 2, result.Files[0].Transactions)
+		assert.Equal(t, []ParseError{{File: SystemDataFile, Line: 5, Column: "amount", Value: "n/a", Reason: "invalid amount"}}, result.ParseErrors)
+	})
+
+	t.Run("unknown sheet", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader("trxID,amount,type,transactionTime\n"), newBankForm(t, "bank.xlsx", string(bank)), nil, nil, ReconcileOptions{SheetName: "Sheet2"})
+
+		require.NoError(t, err)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, `sheet "Sheet2" not found (expected one of Statement)`, result.Files[0].Error)
+	})
+}
+
+func TestReconcileGivenTransactions(t *testing.T) {
+	service := NewReconciliationService()
+	at := func(d, h int) time.Time { return time.Date(2025, 1, d, h, 0, 0, 0, time.UTC) }
+
+	system := []SystemTransaction{
+		{TransactionID: "TRX001", Amount: 10050, Type: Credit, TransactionTime: at(15, 10)},
+		{TransactionID: "TRX002", Amount: 25000, Currency: "usd", Type: Debit, TransactionTime: at(16, 9)},
+		{TransactionID: "TRX003", Amount: 100, Type: Credit, TransactionTime: at(17, 9)},
+	}
+	bank := []BankTransaction{
+		{BankName: "BANK", UniqueID: "B1", Amount: 10050, Date: at(15, 0)},
+		{BankName: "BANK", UniqueID: "B2", Amount: -25000, Currency: "USD", Date: at(16, 0)},
+		{BankName: "BANK", UniqueID: "B3", Amount: 100, Date: at(14, 0)},
+	}
+
+	t.Run("without files", func(t *testing.T) {
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", nil, nil, system, bank, ReconcileOptions{Currency: "USD"})
+
+		require.NoError(t, err)
+		// TRX003 and B3 are outside the period
+		assert.Equal(t, 4, result.TotalProcessed)
+		assert.Equal(t, 2, result.TotalMatched)
+		assert.Empty(t, result.UnmatchedBank)
+		assert.Equal(t, "USD", result.Matched[0].System.Currency)
+		assert.Empty(t, result.Files)
+	})
+
+	t.Run("with files", func(t *testing.T) {
+		sysData := "trxID,amount,type,transactionTime\nTRX004,75.00,CREDIT,2025-01-16 11:00:00\n"
+		result, err := service.Reconcile("2025-01-15", "2025-01-16", strings.NewReader(sysData), newBankForm(t, "bank.csv", "unique_identifier,amount,date\nB4,75.00,2025-01-16\n"), system[:1], bank[:1], ReconcileOptions{Currency: "USD"})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.TotalMatched)
+		require.Len(t, result.Files, 1)
+		assert.Equal(t, 1, result.Files[0].Transactions)
+	})
 }
@@ -0,0 +1,142 @@
+package reconciliation
+
+// maxSplitCandidates caps how many transactions are considered for a single
+// split match so the subset search stays bounded.
+const maxSplitCandidates = 25
+
+type splitMatcher struct{}
+
+// SplitMatcher runs the one-to-many and many-to-one matching stage. It groups
+// bank lines whose sum equals a system amount, then system transactions whose
+// sum equals a bank amount, with at most the request MaxGroupSize members on
+// the split side. It is disabled when MaxGroupSize is below two.
+func SplitMatcher() Matcher {
+	return splitMatcher{}
+}
+
+func (splitMatcher) Name() string {
+	return string(RuleSplit)
+}
+
+func (splitMatcher) Match(system []SystemTransaction, bank []BankTransaction, opts ReconcileOptions) (matches []Match) {
+	if opts.MaxGroupSize < 2 {
+		return nil
+	}
+
+	bankMapByDate := bankIndexByDate(bank)
+	usedSystem := make([]bool, len(system))
+	usedBank := make([]bool, len(bank))
+
+	// one system transaction split across several bank lines
+	for i, sys := range system {
+		target := getSignedAmount(sys)
+
+		var candidates []int
+		var amounts []Money
+		for _, date := range opts.Window.dates(dayOf(sys.TransactionTime)) {
+			for _, idx := range bankMapByDate[date.Format(BankTimeFormat)] {
+				if !usedBank[idx] && bank[idx].Currency == sys.Currency && isSplitPart(bank[idx].Amount, target) {
+					candidates = append(candidates, idx)
+					amounts = append(amounts, bank[idx].Amount)
+				}
+			}
+		}
+
+		picked := findSubset(amounts, target, opts.MaxGroupSize)
+		if picked == nil {
+			continue
+		}
+
+		match := Match{System: []int{i}}
+		for _, p := range picked {
+			usedBank[candidates[p]] = true
+			match.Bank = append(match.Bank, candidates[p])
+		}
+		usedSystem[i] = true
+		matches = append(matches, match)
+	}
+
+	// several system transactions batched into one bank line
+	sysByPostingDate := make(map[string][]int)
+	for i, sys := range system {
+		if usedSystem[i] {
+			continue
+		}
+		for _, date := range opts.Window.dates(dayOf(sys.TransactionTime)) {
+			dKey := date.Format(BankTimeFormat)
+			sysByPostingDate[dKey] = append(sysByPostingDate[dKey], i)
+		}
+	}
+
+	for idx, b := range bank {
+		if usedBank[idx] {
+			continue
+		}
+
+		var candidates []int
+		var amounts []Money
+		for _, i := range sysByPostingDate[b.Date.Format(BankTimeFormat)] {
+			amount := getSignedAmount(system[i])
+			if !usedSystem[i] && system[i].Currency == b.Currency && isSplitPart(amount, b.Amount) {
+				candidates = append(candidates, i)
+				amounts = append(amounts, amount)
+			}
+		}
+
+		picked := findSubset(amounts, b.Amount, opts.MaxGroupSize)
+		if picked == nil {
+			continue
+		}
+
+		match := Match{Bank: []int{idx}}
+		for _, p := range picked {
+			usedSystem[candidates[p]] = true
+			match.System = append(match.System, candidates[p])
+		}
+		usedBank[idx] = true
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// isSplitPart reports whether amount can be one part of a split of total:
+// same sign and strictly smaller in size.
+func isSplitPart(amount, total Money) bool {
+	if amount == 0 || (amount < 0) != (total < 0) {
+		return false
+	}
+	return absMoney(amount) < absMoney(total)
+}
+
+// findSubset returns the indices of the smallest group of 2 to maxSize amounts
+// that sum exactly to target, or nil when there is none. Only the first
+// maxSplitCandidates amounts are considered.
+func findSubset(amounts []Money, target Money, maxSize int) []int {
+	if len(amounts) > maxSplitCandidates {
+		amounts = amounts[:maxSplitCandidates]
+	}
+
+	for size := 2; size <= maxSize && size <= len(amounts); size++ {
+		if picked := subsetOfSize(amounts, target, size, 0, nil); picked != nil {
+			return picked
+		}
+	}
+	return nil
+}
+
+func subsetOfSize(amounts []Money, target Money, size, start int, picked []int) []int {
+	if size == 0 {
+		if target == 0 {
+			return picked
+		}
+		return nil
+	}
+
+	for i := start; i <= len(amounts)-size; i++ {
+		if found := subsetOfSize(amounts, target-amounts[i], size-1, i+1, append(picked, i)); found != nil {
+			return found
+		}
+	}
+	return nil
+}
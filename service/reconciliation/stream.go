@@ -0,0 +1,382 @@
+package reconciliation
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Stream reconciles uploads read one at a time. Transactions are spooled to
+// disk by day as they are read, and matching then runs one system day at a
+// time against the bank lines of its settlement window, so only those days
+// are held in memory. Unlike Reconcile, a split group therefore never joins
+// system transactions of different days, and a reference match is only found
+// within the settlement window.
+//
+// A Stream is not safe for concurrent use and must be closed to remove its
+// spool files.
+type Stream struct {
+	s           *reconciliationService
+	req         request
+	spool       *daySpool
+	fxRates     *FXRates
+	files       []FileReport
+	parseErrors []ParseError
+	bankFiles   int
+	processed   int
+}
+
+// NewStream starts a streaming reconciliation of the period, spooling to a
+// temporary directory.
+func (s *reconciliationService) NewStream(startDate, endDate string, opts ReconcileOptions) (*Stream, error) {
+	req, err := s.newRequest(startDate, endDate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	spool, err := newDaySpool()
+	if err != nil {
+		return nil, err
+	}
+
+	fxRates := &FXRates{}
+	fxRates.Merge(s.fxRates)
+	fxRates.Merge(req.opts.FXRates)
+
+	return &Stream{s: s, req: req, spool: spool, fxRates: fxRates}, nil
+}
+
+// AddSystemData reads a system export, CSV or XLSX. Rows that cannot be
+// parsed are reported by Finish.
+func (st *Stream) AddSystemData(r io.Reader) error {
+	parseErrors, err := readSystemData(r, st.req, func(trx SystemTransaction) error {
+		trxs, _ := withCurrency([]SystemTransaction{trx}, nil, st.req.opts.Currency)
+		day := dayOf(trx.TransactionTime.In(st.req.opts.Timezone)).Format(BankTimeFormat)
+		st.processed++
+		return st.spool.add(systemBucket, day, trxs[0])
+	})
+	if err != nil {
+		var spoolErr *spoolError
+		if errors.As(err, &spoolErr) {
+			return err
+		}
+		return fmt.Errorf("failed to load system transactions: %v", err)
+	}
+	st.parseErrors = append(st.parseErrors, parseErrors...)
+	return nil
+}
+
+// AddBankFile reads the next bank statement upload, with the format and
+// profile chosen for it by the request. A file that cannot be read is reported
+// by Finish; only spooling failures are returned.
+func (st *Stream) AddBankFile(filename string, r io.Reader) error {
+	n := st.bankFiles
+	st.bankFiles++
+
+	report, parseErrors, err := st.s.readBankFile(filename, r, n, st.req, func(trx BankTransaction) error {
+		_, trxs := withCurrency(nil, []BankTransaction{trx}, st.req.opts.Currency)
+		st.processed++
+		return st.spool.add(bankBucket, dayOf(trx.Date).Format(BankTimeFormat), trxs[0])
+	})
+	if err != nil {
+		return err
+	}
+	st.files = append(st.files, report)
+	st.parseErrors = append(st.parseErrors, parseErrors...)
+	return nil
+}
+
+// AddFXRates reads an exchange rates upload, overriding the configured rates.
+func (st *Stream) AddFXRates(r io.Reader) error {
+	uploaded, err := LoadFXRates(r)
+	if err != nil {
+		return fmt.Errorf("invalid fx_rates: %v", err)
+	}
+	st.fxRates.Merge(uploaded)
+	return nil
+}
+
+// Finish matches the spooled transactions day by day and returns the
+// result.
+func (st *Stream) Finish() (ReconciliationResult, error) {
+	if st.req.opts.Strict && len(st.parseErrors) > 0 {
+		return ReconciliationResult{}, &StrictModeError{Errors: st.parseErrors}
+	}
+	if err := st.spool.flush(); err != nil {
+		return ReconciliationResult{}, err
+	}
+
+	opts := st.req.opts
+	opts.FXRates = st.fxRates
+
+	result := ReconciliationResult{
+		UnmatchedBank:    make(map[string][]BankTransaction),
+		TotalProcessed:   st.processed,
+		Tolerance:        opts.Tolerance,
+		BankTolerances:   opts.BankTolerances,
+		SettlementWindow: opts.Window,
+		MaxGroupSize:     opts.MaxGroupSize,
+		MatchMode:        opts.Mode,
+		MinConfidence:    opts.MinConfidence,
+		Files:            st.files,
+		ParseErrors:      st.parseErrors,
+	}
+
+	days := st.spool.days()
+	pending := make(map[string][]BankTransaction)
+	loaded := make(map[string]bool)
+
+	for i, day := range days {
+		system, err := readSpool[SystemTransaction](st.spool, systemBucket, day)
+		if err != nil {
+			return ReconciliationResult{}, err
+		}
+
+		// bank lines of the days a system transaction of this day may settle on
+		last := st.lastDate(day)
+		var window []string
+		for _, d := range days[i:] {
+			if d > last {
+				break
+			}
+			if !loaded[d] {
+				if pending[d], err = readSpool[BankTransaction](st.spool, bankBucket, d); err != nil {
+					return ReconciliationResult{}, err
+				}
+				loaded[d] = true
+			}
+			window = append(window, d)
+		}
+
+		var bank []BankTransaction
+		for _, d := range window {
+			bank = append(bank, pending[d]...)
+		}
+
+		part := reconcileWith(st.s.matchers, system, bank, opts)
+		result.Matched = append(result.Matched, part.Matched...)
+		result.Discrepancies = append(result.Discrepancies, part.Discrepancies...)
+		result.GroupMatches = append(result.GroupMatches, part.GroupMatches...)
+		result.UnmatchedSystem = append(result.UnmatchedSystem, part.UnmatchedSystem...)
+		result.Fees = append(result.Fees, part.Fees...)
+
+		// keep the lines left unmatched, in file order, for the next days
+		left := make(map[BankTransaction]int)
+		for _, lines := range part.UnmatchedBank {
+			for _, b := range lines {
+				left[b]++
+			}
+		}
+		for _, d := range window {
+			var rest []BankTransaction
+			for _, b := range pending[d] {
+				if left[b] > 0 {
+					left[b]--
+					rest = append(rest, b)
+				}
+			}
+			pending[d] = rest
+		}
+
+		// no later system day can settle on this day
+		for _, b := range pending[day] {
+			result.UnmatchedBank[b.BankName] = append(result.UnmatchedBank[b.BankName], b)
+		}
+		delete(pending, day)
+	}
+
+	result.TotalMatched = len(result.Matched)
+	result.TotalGroupMatched = len(result.GroupMatches)
+	result.TotalUnmatched = len(result.UnmatchedSystem)
+	for _, v := range result.UnmatchedBank {
+		result.TotalUnmatched += len(v)
+	}
+	result.Currencies = currencyTotals(result)
+	result.TotalDiscrepancies, result.TotalFees = overallTotals(result.Currencies)
+
+	return result, nil
+}
+
+// lastDate returns the latest bank day, as a day key, on which a system
+// transaction of day may settle under the request or rule windows.
+func (st *Stream) lastDate(day string) string {
+	d, _ := time.Parse(BankTimeFormat, day)
+	last := st.req.opts.Window.lastDate(d)
+	for _, rule := range st.s.rules {
+		if l := rule.Window.lastDate(d); l.After(last) {
+			last = l
+		}
+	}
+	return last.Format(BankTimeFormat)
+}
+
+// Close removes the spool files.
+func (st *Stream) Close() error {
+	return st.spool.close()
+}
+
+const (
+	systemBucket = "system"
+	bankBucket   = "bank"
+)
+
+// maxOpenSpoolFiles bounds the spool files a daySpool keeps open while
+// adding, so uploads spanning many days do not run out of file descriptors.
+const maxOpenSpoolFiles = 64
+
+// daySpool appends values to gob files per bucket and day. A file closed to
+// stay within maxOpen is not appended to again, since a gob stream cannot be
+// resumed; the next value of its bucket and day starts a new segment.
+type daySpool struct {
+	dir      string
+	maxOpen  int
+	files    map[string]*spoolFile
+	open     []string
+	segments map[string]int
+	keys     map[string]bool
+}
+
+type spoolFile struct {
+	f   *os.File
+	buf *bufio.Writer
+	enc *gob.Encoder
+}
+
+// spoolError reports a failure of the spool files themselves.
+type spoolError struct {
+	err error
+}
+
+func (e *spoolError) Error() string {
+	return fmt.Sprintf("spool: %v", e.err)
+}
+
+func newDaySpool() (*daySpool, error) {
+	dir, err := os.MkdirTemp("", "reconciliation-*")
+	if err != nil {
+		return nil, &spoolError{err}
+	}
+	return &daySpool{
+		dir:      dir,
+		maxOpen:  maxOpenSpoolFiles,
+		files:    make(map[string]*spoolFile),
+		segments: make(map[string]int),
+		keys:     make(map[string]bool),
+	}, nil
+}
+
+func (sp *daySpool) path(name string, segment int) string {
+	return filepath.Join(sp.dir, fmt.Sprintf("%s-%d.gob", name, segment))
+}
+
+func (sp *daySpool) add(bucket, day string, v any) error {
+	name := bucket + "-" + day
+	file, ok := sp.files[name]
+	if !ok {
+		// close the file opened first, as uploads mostly come in date order
+		if len(sp.open) >= sp.maxOpen {
+			if err := sp.closeFile(sp.open[0]); err != nil {
+				return err
+			}
+			sp.open = sp.open[1:]
+		}
+
+		f, err := os.Create(sp.path(name, sp.segments[name]))
+		if err != nil {
+			return &spoolError{err}
+		}
+		buf := bufio.NewWriter(f)
+		file = &spoolFile{f: f, buf: buf, enc: gob.NewEncoder(buf)}
+		sp.files[name] = file
+		sp.open = append(sp.open, name)
+		sp.segments[name]++
+		sp.keys[day] = true
+	}
+	if err := file.enc.Encode(v); err != nil {
+		return &spoolError{err}
+	}
+	return nil
+}
+
+// closeFile writes and closes the open segment of name.
+func (sp *daySpool) closeFile(name string) error {
+	file := sp.files[name]
+	delete(sp.files, name)
+	err := file.buf.Flush()
+	if cerr := file.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return &spoolError{err}
+	}
+	return nil
+}
+
+// flush writes and closes every open spool file.
+func (sp *daySpool) flush() error {
+	for len(sp.open) > 0 {
+		if err := sp.closeFile(sp.open[0]); err != nil {
+			return err
+		}
+		sp.open = sp.open[1:]
+	}
+	return nil
+}
+
+// days returns the spooled day keys in order.
+func (sp *daySpool) days() []string {
+	days := make([]string, 0, len(sp.keys))
+	for day := range sp.keys {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days
+}
+
+// readSpool decodes the values spooled for bucket and day, in the order they
+// were added.
+func readSpool[T any](sp *daySpool, bucket, day string) ([]T, error) {
+	name := bucket + "-" + day
+	var out []T
+	for segment := range sp.segments[name] {
+		values, err := readSegment[T](sp.path(name, segment))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, values...)
+	}
+	return out, nil
+}
+
+func readSegment[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &spoolError{err}
+	}
+	defer f.Close()
+
+	var out []T
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	for {
+		var v T
+		if err := dec.Decode(&v); err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, &spoolError{err}
+		}
+		out = append(out, v)
+	}
+}
+
+func (sp *daySpool) close() error {
+	for _, file := range sp.files {
+		file.f.Close()
+	}
+	return os.RemoveAll(sp.dir)
+}
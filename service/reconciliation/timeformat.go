@@ -0,0 +1,69 @@
+package reconciliation
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// UnixLayout reads a time as Unix epoch seconds.
+	UnixLayout = "unix"
+	// UnixMilliLayout reads a time as Unix epoch milliseconds.
+	UnixMilliLayout = "unix_ms"
+
+	// unixMilliThreshold tells milliseconds from seconds when both Unix
+	// layouts are accepted: 10^11 seconds is past the year 5000, while 10^11
+	// milliseconds is in 1973.
+	unixMilliThreshold = 1e11
+)
+
+// DefaultSystemTimeLayouts are the layouts tried, in order, for system
+// transaction times when a profile sets none: the legacy layout, the same
+// without zero padding or seconds, RFC 3339 with or without offset, and Unix
+// epoch seconds and milliseconds, told apart by magnitude.
+var DefaultSystemTimeLayouts = []string{
+	SystemTimeFormat,
+	"2006-1-2 15:4:5",
+	"2006-1-2 15:4",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	UnixLayout,
+	UnixMilliLayout,
+}
+
+// parseTime parses value with the first of layouts that accepts it. Times
+// without offset are read in loc. When layouts has both Unix layouts, epochs
+// of 10^11 or more are read as milliseconds and smaller ones as seconds,
+// whatever the order.
+func parseTime(value string, layouts []string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		switch layout {
+		case UnixLayout, UnixMilliLayout:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			millis := layout == UnixMilliLayout
+			if slices.Contains(layouts, UnixLayout) && slices.Contains(layouts, UnixMilliLayout) {
+				millis = n >= unixMilliThreshold || n <= -unixMilliThreshold
+			}
+			if millis {
+				return time.UnixMilli(n).In(loc), nil
+			}
+			return time.Unix(n, 0).In(loc), nil
+		default:
+			if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	if len(layouts) == 1 {
+		return time.Time{}, fmt.Errorf("invalid time (expected layout %s)", layouts[0])
+	}
+	return time.Time{}, fmt.Errorf("invalid time (expected one of layouts %s)", strings.Join(layouts, ", "))
+}
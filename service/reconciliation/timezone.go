@@ -0,0 +1,88 @@
+package reconciliation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elkoshar/reconciliation-app/pkg/constants"
+)
+
+// Timezones tells in which time zone system timestamps, bank statement dates
+// and the start_date/end_date filter are written. Transactions are bucketed
+// into days in the Filter zone.
+type Timezones struct {
+	System *time.Location
+	Bank   *time.Location
+	Filter *time.Location
+}
+
+// DefaultTimezone returns Asia/Jakarta, or a fixed UTC+7 zone when the time
+// zone database is not available.
+func DefaultTimezone() *time.Location {
+	if constants.JAKARTA_LOCATION != nil {
+		return constants.JAKARTA_LOCATION
+	}
+	return time.FixedZone("WIB", 7*60*60)
+}
+
+// DefaultTimezones uses DefaultTimezone for every zone.
+func DefaultTimezones() Timezones {
+	loc := DefaultTimezone()
+	return Timezones{System: loc, Bank: loc, Filter: loc}
+}
+
+// withDefaults fills the zones left nil with DefaultTimezone.
+func (tz Timezones) withDefaults() Timezones {
+	def := DefaultTimezones()
+	if tz.System == nil {
+		tz.System = def.System
+	}
+	if tz.Bank == nil {
+		tz.Bank = def.Bank
+	}
+	if tz.Filter == nil {
+		tz.Filter = def.Filter
+	}
+	return tz
+}
+
+// LoadTimezone returns the time zone of an IANA name such as Asia/Jakarta or
+// UTC, or DefaultTimezone for an empty name.
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultTimezone(), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q (expected IANA name such as Asia/Jakarta)", name)
+	}
+	return loc, nil
+}
+
+// WithTimezones sets the time zones of the uploaded data and of the date
+// filter. Zones left nil default to DefaultTimezone.
+func WithTimezones(tz Timezones) Option {
+	return func(s *reconciliationService) {
+		s.timezones = tz.withDefaults()
+	}
+}
+
+// inLocation returns the system transactions with their time in loc, so that
+// their calendar day is the day in loc.
+func inLocation(system []SystemTransaction, loc *time.Location) []SystemTransaction {
+	out := make([]SystemTransaction, len(system))
+	for i, sys := range system {
+		sys.TransactionTime = sys.TransactionTime.In(loc)
+		out[i] = sys
+	}
+	return out
+}
+
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,434 @@
+package reconciliation
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxSignature starts every XLSX file, a zip archive.
+const xlsxSignature = "PK\x03\x04"
+
+// maxXLSXPart bounds the uncompressed size of a workbook part, so a crafted
+// archive cannot exhaust memory.
+const maxXLSXPart = 256 << 20
+
+// xlsxCell is a cell value as stored. Numbers keep their raw text and date
+// tells whether their number format shows a date.
+type xlsxCell struct {
+	value  string
+	number bool
+	date   bool
+}
+
+// xlsxReader reads the rows of one worksheet of an XLSX workbook like
+// csv.Reader, from the header row on. Dates are written with DateLayout and
+// numbers with DecimalSeparator, so the cells parse like those of a CSV
+// export described by the same profile.
+type xlsxReader struct {
+	DateLayout       string
+	DecimalSeparator string
+
+	rows     [][]xlsxCell
+	lines    []int
+	next     int
+	date1904 bool
+}
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// xlsxString is a shared or inline string, plain or made of rich text runs.
+type xlsxString struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s xlsxString) text() string {
+	if len(s.Runs) == 0 {
+		return s.T
+	}
+	var b strings.Builder
+	for _, r := range s.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		R  string     `xml:"r,attr"`
+		T  string     `xml:"t,attr"`
+		S  int        `xml:"s,attr"`
+		V  string     `xml:"v"`
+		Is xlsxString `xml:"is"`
+	} `xml:"c"`
+}
+
+// newXLSXReader opens the worksheet named sheet of the workbook read from r,
+// the first one when sheet is empty, and skips the rows before headerRow,
+// counted from one. The workbook is read into memory.
+func newXLSXReader(r io.Reader, sheet string, headerRow int) (*xlsxReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %v", err)
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		parts[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := decodeXLSXPart(parts, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("invalid XLSX file: no worksheet")
+	}
+
+	sheetID := ""
+	names := make([]string, len(workbook.Sheets))
+	for i, s := range workbook.Sheets {
+		names[i] = s.Name
+		if sheetID == "" && (sheet == "" || strings.EqualFold(strings.TrimSpace(s.Name), strings.TrimSpace(sheet))) {
+			sheetID = s.ID
+		}
+	}
+	if sheetID == "" {
+		return nil, fmt.Errorf("sheet %q not found (expected one of %s)", sheet, strings.Join(names, ", "))
+	}
+	sheetPart := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == sheetID {
+			sheetPart = rel.Target
+		}
+	}
+	if strings.HasPrefix(sheetPart, "/") {
+		sheetPart = strings.TrimPrefix(sheetPart, "/")
+	} else {
+		sheetPart = path.Join("xl", sheetPart)
+	}
+
+	var shared []string
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxString `xml:"si"`
+		}
+		if err := decodeXLSXPart(parts, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		shared = make([]string, len(sst.Items))
+		for i, si := range sst.Items {
+			shared[i] = si.text()
+		}
+	}
+
+	var dateStyles []bool
+	if _, ok := parts["xl/styles.xml"]; ok {
+		var styles xlsxStyles
+		if err := decodeXLSXPart(parts, "xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+		custom := make(map[int]string, len(styles.NumFmts))
+		for _, f := range styles.NumFmts {
+			custom[f.ID] = f.Code
+		}
+		dateStyles = make([]bool, len(styles.CellXfs))
+		for i, xf := range styles.CellXfs {
+			dateStyles[i] = isDateFormat(xf.NumFmtID, custom[xf.NumFmtID])
+		}
+	}
+
+	x := &xlsxReader{date1904: workbook.Properties.Date1904 == "1" || workbook.Properties.Date1904 == "true"}
+	if err := x.readSheet(parts, sheetPart, headerRow, shared, dateStyles); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// readSheet loads the rows of the worksheet from headerRow on, skipping
+// rows without any value.
+func (x *xlsxReader) readSheet(parts map[string]*zip.File, name string, headerRow int, shared []string, dateStyles []bool) error {
+	f, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("invalid XLSX file: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX file: %v", err)
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxXLSXPart))
+	line := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid XLSX file: %v", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok || el.Name.Local != "row" {
+			continue
+		}
+
+		var row xlsxRow
+		if err := dec.DecodeElement(&row, &el); err != nil {
+			return fmt.Errorf("invalid XLSX file: %v", err)
+		}
+		line++
+		if row.R > 0 {
+			line = row.R
+		}
+		if line < headerRow {
+			continue
+		}
+
+		var cells []xlsxCell
+		empty := true
+		for _, c := range row.Cells {
+			col := len(cells)
+			if c.R != "" {
+				if col, err = xlsxColumn(c.R); err != nil {
+					return fmt.Errorf("invalid XLSX file: %v", err)
+				}
+			}
+			for len(cells) <= col {
+				cells = append(cells, xlsxCell{})
+			}
+
+			cell := xlsxCell{value: c.V}
+			switch c.T {
+			case "s":
+				i, err := strconv.Atoi(strings.TrimSpace(c.V))
+				if err != nil || i < 0 || i >= len(shared) {
+					return fmt.Errorf("invalid XLSX file: cell %s: invalid shared string %q", c.R, c.V)
+				}
+				cell.value = shared[i]
+			case "inlineStr":
+				cell.value = c.Is.text()
+			case "b":
+				cell.value = strings.ToUpper(strconv.FormatBool(c.V == "1"))
+			case "d":
+				cell.date = true
+			case "", "n":
+				cell.number = true
+				cell.date = c.S >= 0 && c.S < len(dateStyles) && dateStyles[c.S]
+			}
+			if strings.TrimSpace(cell.value) != "" {
+				empty = false
+			}
+			cells[col] = cell
+		}
+		if empty {
+			continue
+		}
+		x.rows = append(x.rows, cells)
+		x.lines = append(x.lines, line)
+	}
+}
+
+// header returns the header row without consuming it, nil when the sheet
+// has no rows.
+func (x *xlsxReader) header() []string {
+	if x.next >= len(x.rows) {
+		return nil
+	}
+	return x.render(x.rows[x.next])
+}
+
+// Read returns the next row, or io.EOF.
+func (x *xlsxReader) Read() ([]string, error) {
+	if x.next >= len(x.rows) {
+		return nil, io.EOF
+	}
+	x.next++
+	return x.render(x.rows[x.next-1]), nil
+}
+
+// FieldPos returns the sheet row of the last row read, so parse errors
+// point at the spreadsheet row.
+func (x *xlsxReader) FieldPos(field int) (line, column int) {
+	if x.next == 0 {
+		return 0, 0
+	}
+	return x.lines[x.next-1], field + 1
+}
+
+func (x *xlsxReader) render(row []xlsxCell) []string {
+	record := make([]string, len(row))
+	for i, c := range row {
+		record[i] = x.cellText(c)
+	}
+	return record
+}
+
+// cellText writes a cell the way a CSV export would hold it.
+func (x *xlsxReader) cellText(c xlsxCell) string {
+	layout := x.DateLayout
+	if layout == "" || layout == UnixLayout || layout == UnixMilliLayout {
+		layout = SystemTimeFormat
+	}
+
+	switch {
+	case c.date && !c.number:
+		t, err := parseTime(c.value, []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", BankTimeFormat}, time.UTC)
+		if err != nil {
+			return c.value
+		}
+		return t.Format(layout)
+	case c.date:
+		serial, err := strconv.ParseFloat(c.value, 64)
+		if err != nil {
+			return c.value
+		}
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if x.date1904 {
+			epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return epoch.Add(time.Duration(math.Round(serial*86400)) * time.Second).Format(layout)
+	case c.number:
+		// plain decimals within the 15 significant digits Excel keeps are
+		// kept as written, so that IDs and amounts stay exact; longer ones,
+		// such as the float noise of 0.30000000000000004, and values in
+		// exponent form are rounded to 15 significant digits
+		s := c.value
+		if !isPlainDecimal(s) || significantDigits(s) > 15 {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return c.value
+			}
+			f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		if x.DecimalSeparator != "" && x.DecimalSeparator != "." {
+			s = strings.Replace(s, ".", x.DecimalSeparator, 1)
+		}
+		return s
+	}
+	return c.value
+}
+
+// isPlainDecimal tells whether s is an optionally negative decimal number
+// without exponent.
+func isPlainDecimal(s string) bool {
+	intPart, frac, hasFrac := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	return intPart != "" && isDigits(intPart) && (!hasFrac || frac != "" && isDigits(frac))
+}
+
+// significantDigits counts the digits of a plain decimal from its first to
+// its last non-zero digit.
+func significantDigits(s string) int {
+	return len(strings.Trim(strings.Replace(strings.TrimPrefix(s, "-"), ".", "", 1), "0"))
+}
+
+// isDateFormat tells whether a number format shows a date or time: one of
+// the built-in date formats, or a custom format code with date or time
+// parts outside quoted text and brackets.
+func isDateFormat(id int, code string) bool {
+	switch {
+	case id >= 14 && id <= 22, id >= 27 && id <= 36, id >= 45 && id <= 47, id >= 50 && id <= 58:
+		return true
+	case code == "":
+		return false
+	}
+
+	inQuote, inBracket, escaped := false, false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case strings.ContainsRune("ymdhs", r):
+			return true
+		}
+	}
+	return false
+}
+
+// xlsxColumn returns the zero-based column of a cell reference such as C12.
+func xlsxColumn(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range strings.ToUpper(ref) {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+func decodeXLSXPart(parts map[string]*zip.File, name string, v any) error {
+	f, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("invalid XLSX file: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX file: %v", err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPart)).Decode(v); err != nil {
+		return fmt.Errorf("invalid XLSX file: %s: %v", name, err)
+	}
+	return nil
+}